@@ -0,0 +1,191 @@
+package commando
+
+import (
+	"errors"
+	"os"
+	"path"
+	"regexp"
+
+	"github.com/scrapli/scrapligocfg"
+)
+
+// loadInventoryFile loads the inventory files without requiring any devices to be defined.
+// It returns nil if no inventory is set and the default inventory file doesn't exist.
+// The missing files set with the flags are an error.
+func (app *appCfg) loadInventoryFile() (*inventory, error) {
+	if !app.inventorySet {
+		if _, err := os.Stat(defaultInventory); errors.Is(err, os.ErrNotExist) {
+			app.inventories = nil
+		}
+	}
+
+	if len(app.inventories) == 0 && app.ansibleInv == "" {
+		return nil, nil
+	}
+
+	files, err := app.readInventoryFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	i := &inventory{}
+	if err := mergeInventory(i, files); err != nil {
+		return nil, err
+	}
+
+	return i, i.resolveVars()
+}
+
+// runAnalyze runs the analysis over the outputs saved in the app.analyzeFrom directory
+// without connecting to the devices.
+// Devices' platforms, tags and normalisation rules are taken from the inventory if it exists.
+func (app *appCfg) runAnalyze() error {
+	if app.analyzeFrom == "" {
+		return errNoAnalyzeDir
+	}
+
+	outs, err := loadOutputDir(app.analyzeFrom)
+	if err != nil {
+		return err
+	}
+
+	i, err := app.loadInventoryFile()
+	if err != nil {
+		return err
+	}
+
+	if i == nil {
+		i = &inventory{}
+	}
+
+	devs := map[string]*device{}
+
+	for name := range outs {
+		if app.devFilter != "" && !regexp.MustCompile(app.devFilter).MatchString(name) {
+			delete(outs, name)
+
+			continue
+		}
+
+		d, ok := i.Devices[name]
+		if !ok {
+			d = &device{}
+		}
+
+		devs[name] = d
+	}
+
+	if len(devs) == 0 {
+		return errNoDevices
+	}
+
+	if app.normalizer, err = newNormalizer(i.Normalize, devs); err != nil {
+		return err
+	}
+
+	// the compliance rules and state tests are evaluated on the raw outputs where they were saved
+	raw := rawOutputs(app.analyzeFrom, outs)
+	outs = app.normalizer.applySet(outs)
+
+	var reports []*checkReport
+
+	if app.rulesFile != "" {
+		rules, err := loadRules(app.rulesFile)
+		if err != nil {
+			return err
+		}
+
+		reports = append(reports, checkCompliance(rules, devs, raw))
+	}
+
+	if app.testsFile != "" {
+		tests, err := loadTests(app.testsFile)
+		if err != nil {
+			return err
+		}
+
+		reports = append(reports, runTests(tests, devs, raw))
+	}
+
+	if app.analyzeDrift {
+		reports = append(reports, app.offlineDrift(devs, outs))
+	}
+
+	if app.diffAgainst != "" {
+		prev, err := loadOutputDir(app.diffAgainst)
+		if err != nil {
+			return err
+		}
+
+		diffOutputs(app.diffAgainst, app.analyzeFrom, app.normalizer.applySet(prev), outs,
+			sortedKeys(devs)...).write(os.Stdout)
+	}
+
+	return app.writeReports(reports...)
+}
+
+// offlineDrift compares the saved get-config outputs with the intended configs of the devices.
+// Both configs are normalised the same way the drift subcommand does.
+func (app *appCfg) offlineDrift(devs map[string]*device, outs outputSet) *checkReport {
+	rep := &checkReport{Name: "drift"}
+
+	for _, name := range sortedKeys(devs) {
+		d := devs[name]
+		if d.IntendedConfig == "" {
+			continue
+		}
+
+		res := &checkResult{Device: name, Check: driftCheckName}
+		rep.Results = append(rep.Results, res)
+
+		running, ok := outs[name][scrapligocfg.GetConfig]
+		if !ok {
+			res.Error = "no output collected for " + scrapligocfg.GetConfig
+
+			continue
+		}
+
+		intended, err := intendedConfig(name, d)
+		if err != nil {
+			res.Error = err.Error()
+
+			continue
+		}
+
+		// the platform implementation normalises the configs without the connection
+		c, err := scrapligocfg.NewCfg(nil, d.Platform)
+		if err != nil {
+			res.Error = err.Error()
+
+			continue
+		}
+
+		intended = app.normalizer.apply(name, scrapligocfg.GetConfig, c.Impl.NormalizeConfig(intended))
+		running = app.normalizer.apply(name, scrapligocfg.GetConfig, c.Impl.NormalizeConfig(running))
+
+		res.Details = configDrift(intended, running, app.sectionAware)
+		res.Passed = len(res.Details) == 0
+	}
+
+	return rep
+}
+
+// rawOutputs returns the outs with the outputs replaced by the raw ones
+// saved in the dir outputs directory with --keep-raw.
+func rawOutputs(dir string, outs outputSet) outputSet {
+	raw := make(outputSet, len(outs))
+
+	for dev, files := range outs {
+		raw[dev] = make(map[string]string, len(files))
+
+		for f, out := range files {
+			raw[dev][f] = out
+
+			if b, err := os.ReadFile(path.Join(dir, dev, rawOutputDir, f)); err == nil {
+				raw[dev][f] = string(b)
+			}
+		}
+	}
+
+	return raw
+}
@@ -0,0 +1,796 @@
+package commando
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	yamlv2 "gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ansibleAllGroup       = "all"
+	ansibleUngroupedGroup = "ungrouped"
+	ansibleVaultPrefix    = "$ANSIBLE_VAULT"
+	// prefix of the ansible vars setting the cmdo device fields, e.g. cmdo_send_commands.
+	ansibleCmdoVarPrefix = "cmdo_"
+	ansibleDefaultPort   = 22
+	jinjaMarker          = "{{"
+)
+
+// ansibleMappedVars are the ansible vars mapped onto the devices, credentials and transports.
+var ansibleMappedVars = []string{ //nolint:gochecknoglobals
+	"ansible_host", "ansible_network_os", "ansible_user", "ansible_port",
+	"ansible_password", "ansible_ssh_pass", "ansible_ssh_password",
+	"ansible_become_password", "ansible_become_pass",
+	"ansible_ssh_private_key_file", "ansible_private_key_file",
+}
+
+// ansiblePlatforms maps the ansible_network_os values onto the platforms.
+var ansiblePlatforms = map[string]string{ //nolint:gochecknoglobals
+	"eos":                         "arista_eos",
+	"arista.eos.eos":              "arista_eos",
+	"ios":                         "cisco_iosxe",
+	"cisco.ios.ios":               "cisco_iosxe",
+	"iosxr":                       "cisco_iosxr",
+	"cisco.iosxr.iosxr":           "cisco_iosxr",
+	"nxos":                        "cisco_nxos",
+	"cisco.nxos.nxos":             "cisco_nxos",
+	"junos":                       "juniper_junos",
+	"junipernetworks.junos.junos": "juniper_junos",
+	"sros":                        "nokia_sros",
+	"nokia.sros.md":               "nokia_sros",
+	"nokia.sros.classic":          "nokia_sros_classic",
+	"srlinux":                     "nokia_srlinux",
+	"nokia.srlinux.srlinux":       "nokia_srlinux",
+}
+
+// ansibleHostRangeRe matches the ranges of the host patterns, e.g. [01:10] or [a:f:2].
+var ansibleHostRangeRe = regexp.MustCompile(`\[([0-9a-zA-Z]+):([0-9a-zA-Z]+)(?::([0-9]+))?\]`) //nolint:gochecknoglobals
+
+// ansibleInventory is the Ansible inventory: its groups and hosts with their vars.
+type ansibleInventory struct {
+	groups map[string]*ansibleGroup
+	hosts  map[string]map[string]interface{}
+}
+
+type ansibleGroup struct {
+	hosts    []string
+	children []string
+	vars     map[string]interface{}
+}
+
+// ansibleYAMLGroup is the group of the YAML Ansible inventory.
+type ansibleYAMLGroup struct {
+	Hosts    map[string]map[string]interface{} `yaml:"hosts"`
+	Vars     map[string]interface{}            `yaml:"vars"`
+	Children map[string]*ansibleYAMLGroup      `yaml:"children"`
+}
+
+func newAnsibleInventory() *ansibleInventory {
+	a := &ansibleInventory{
+		groups: map[string]*ansibleGroup{},
+		hosts:  map[string]map[string]interface{}{},
+	}
+
+	a.group(ansibleAllGroup)
+	a.group(ansibleUngroupedGroup)
+
+	return a
+}
+
+// group returns the name group, adding it if it doesn't exist.
+func (a *ansibleInventory) group(name string) *ansibleGroup {
+	g, ok := a.groups[name]
+	if !ok {
+		g = &ansibleGroup{vars: map[string]interface{}{}}
+		a.groups[name] = g
+	}
+
+	return g
+}
+
+// addHost adds the hosts matching the host pattern to the group.
+func (a *ansibleInventory) addHost(group, pattern string, vars map[string]interface{}) error {
+	hosts, err := expandAnsibleHosts(pattern)
+	if err != nil {
+		return err
+	}
+
+	g := a.group(group)
+
+	for _, h := range hosts {
+		if !contains(g.hosts, h) {
+			g.hosts = append(g.hosts, h)
+		}
+
+		if _, ok := a.hosts[h]; !ok {
+			a.hosts[h] = map[string]interface{}{}
+		}
+
+		for k, v := range vars {
+			a.hosts[h][k] = v
+		}
+	}
+
+	return nil
+}
+
+// loadAnsibleInventory loads the Ansible inventory file with the group_vars and host_vars
+// found next to it. The .yml, .yaml and .json files are read as YAML inventories, other files as INI.
+func loadAnsibleInventory(path string) (*ansibleInventory, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var a *ansibleInventory
+
+	switch filepath.Ext(path) {
+	case ".yml", ".yaml", ".json":
+		a, err = parseAnsibleYAML(b)
+	default:
+		a, err = parseAnsibleINI(b)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+
+	for _, name := range sortedKeys(a.groups) {
+		vars, err := readAnsibleVars(filepath.Join(dir, "group_vars"), name)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range vars {
+			a.groups[name].vars[k] = v
+		}
+	}
+
+	for _, name := range sortedKeys(a.hosts) {
+		vars, err := readAnsibleVars(filepath.Join(dir, "host_vars"), name)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range vars {
+			a.hosts[name][k] = v
+		}
+	}
+
+	return a, nil
+}
+
+// parseAnsibleINI parses the INI Ansible inventory.
+// The host vars are parsed as YAML scalars, the group vars are kept as strings, as Ansible does.
+func parseAnsibleINI(b []byte) (*ansibleInventory, error) {
+	a := newAnsibleInventory()
+
+	group, kind := ansibleUngroupedGroup, "hosts"
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		// the section header can be followed by a comment
+		if end := strings.IndexByte(line, ']'); line[0] == '[' && end != -1 && isINIComment(line[end+1:]) {
+			group, kind = line[1:end], "hosts"
+
+			if g, k, ok := strings.Cut(group, ":"); ok {
+				group, kind = g, k
+			}
+
+			if kind != "hosts" && kind != "vars" && kind != "children" {
+				return nil, fmt.Errorf("%w: line %d: unknown section type %q", errAnsibleInventory, n, kind)
+			}
+
+			a.group(group)
+
+			continue
+		}
+
+		switch kind {
+		case "hosts":
+			fields, err := splitINIFields(line)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", errAnsibleInventory, n, err)
+			}
+
+			vars := map[string]interface{}{}
+
+			for _, f := range fields[1:] {
+				k, v, ok := strings.Cut(f, "=")
+				if !ok {
+					return nil, fmt.Errorf("%w: line %d: expected key=value, got %q", errAnsibleInventory, n, f)
+				}
+
+				vars[k] = iniValue(v)
+			}
+
+			if err := a.addHost(group, fields[0], vars); err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", errAnsibleInventory, n, err)
+			}
+		case "vars":
+			k, v, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("%w: line %d: expected key=value, got %q", errAnsibleInventory, n, line)
+			}
+
+			a.group(group).vars[strings.TrimSpace(k)] = unquote(strings.TrimSpace(v))
+		case "children":
+			fields, err := splitINIFields(line)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", errAnsibleInventory, n, err)
+			}
+
+			if len(fields) != 1 {
+				return nil, fmt.Errorf("%w: line %d: expected a group name, got %q", errAnsibleInventory, n, line)
+			}
+
+			child := fields[0]
+
+			g := a.group(group)
+			if !contains(g.children, child) {
+				g.children = append(g.children, child)
+			}
+
+			a.group(child)
+		}
+	}
+
+	return a, scanner.Err()
+}
+
+// isINIComment returns true if the rest of the INI line s is blank or a comment.
+func isINIComment(s string) bool {
+	s = strings.TrimSpace(s)
+
+	return s == "" || s[0] == '#' || s[0] == ';'
+}
+
+// splitINIFields splits the INI host line on the whitespace outside of the quotes,
+// dropping the trailing comment.
+func splitINIFields(line string) ([]string, error) {
+	var (
+		fields []string
+		field  strings.Builder
+		quote  rune
+	)
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+
+			field.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+
+			field.WriteRune(r)
+		case r == '#' && field.Len() == 0:
+			return fields, nil
+		case r == ' ' || r == '\t':
+			if field.Len() != 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", line)
+	}
+
+	if field.Len() != 0 {
+		fields = append(fields, field.String())
+	}
+
+	return fields, nil
+}
+
+// iniValue returns the value of the host var with the quotes removed, parsed as a YAML value
+// the way Ansible evaluates them as literals, or the string if it isn't a literal.
+func iniValue(v string) interface{} {
+	v = unquote(v)
+
+	var val interface{}
+	if err := yamlv2.Unmarshal([]byte(v), &val); err == nil {
+		switch val.(type) {
+		case bool, int, float64, []interface{}, map[interface{}]interface{}:
+			return val
+		}
+	}
+
+	return v
+}
+
+func unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+
+	return v
+}
+
+// parseAnsibleYAML parses the YAML Ansible inventory.
+func parseAnsibleYAML(b []byte) (*ansibleInventory, error) {
+	groups := map[string]*ansibleYAMLGroup{}
+	if err := yamlv2.Unmarshal(b, &groups); err != nil {
+		return nil, fmt.Errorf("%w: %v", errAnsibleInventory, err)
+	}
+
+	a := newAnsibleInventory()
+
+	for _, name := range sortedKeys(groups) {
+		if err := a.addYAMLGroup(name, groups[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+func (a *ansibleInventory) addYAMLGroup(name string, yg *ansibleYAMLGroup) error {
+	g := a.group(name)
+
+	if yg == nil {
+		return nil
+	}
+
+	for k, v := range yg.Vars {
+		g.vars[k] = v
+	}
+
+	for _, h := range sortedKeys(yg.Hosts) {
+		if err := a.addHost(name, h, yg.Hosts[h]); err != nil {
+			return fmt.Errorf("%w: %v", errAnsibleInventory, err)
+		}
+	}
+
+	for _, child := range sortedKeys(yg.Children) {
+		if !contains(g.children, child) {
+			g.children = append(g.children, child)
+		}
+
+		if err := a.addYAMLGroup(child, yg.Children[child]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expandAnsibleHosts expands the numeric and alphabetic ranges of the host pattern,
+// e.g. leaf[01:03] expands to leaf01, leaf02 and leaf03.
+func expandAnsibleHosts(pattern string) ([]string, error) {
+	m := ansibleHostRangeRe.FindStringSubmatchIndex(pattern)
+	if m == nil {
+		return []string{pattern}, nil
+	}
+
+	prefix, suffix := pattern[:m[0]], pattern[m[1]:]
+	beg, end := pattern[m[2]:m[3]], pattern[m[4]:m[5]]
+
+	step := 1
+	if m[6] != -1 {
+		step, _ = strconv.Atoi(pattern[m[6]:m[7]])
+	}
+
+	if step < 1 {
+		return nil, fmt.Errorf("invalid range step in %q", pattern)
+	}
+
+	var items []string
+
+	b, errB := strconv.Atoi(beg)
+	e, errE := strconv.Atoi(end)
+
+	switch {
+	case errB == nil && errE == nil:
+		width := 0
+		if len(beg) > 1 && beg[0] == '0' {
+			width = len(beg)
+		}
+
+		for n := b; n <= e; n += step {
+			items = append(items, fmt.Sprintf("%0*d", width, n))
+		}
+	case len(beg) == 1 && len(end) == 1:
+		for c := beg[0]; c <= end[0]; c += byte(step) {
+			items = append(items, string(c))
+		}
+	default:
+		return nil, fmt.Errorf("invalid range in %q", pattern)
+	}
+
+	var hosts []string
+
+	for _, item := range items {
+		expanded, err := expandAnsibleHosts(prefix + item + suffix)
+		if err != nil {
+			return nil, err
+		}
+
+		hosts = append(hosts, expanded...)
+	}
+
+	return hosts, nil
+}
+
+// readAnsibleVars reads the vars of the group or host name from the group_vars or host_vars dir:
+// the name file with no, .yml, .yaml or .json extension, and the files of the name directory
+// in their sorted order.
+func readAnsibleVars(dir, name string) (map[string]interface{}, error) {
+	var files []string
+
+	for _, ext := range []string{"", ".yml", ".yaml", ".json"} {
+		p := filepath.Join(dir, name+ext)
+
+		fi, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+
+		if !fi.IsDir() {
+			files = append(files, p)
+
+			continue
+		}
+
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+				files = append(files, filepath.Join(p, e.Name()))
+			}
+		}
+	}
+
+	vars := map[string]interface{}{}
+
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		fileVars := map[string]interface{}{}
+		if err := yamlv2.Unmarshal(b, &fileVars); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+
+		for k, v := range fileVars {
+			vars[k] = v
+		}
+	}
+
+	return vars, nil
+}
+
+// hostGroups returns the groups of the host, including the groups of its groups,
+// ordered by their depth from the all group and their names, as Ansible applies their vars.
+// The all group is not included.
+func (a *ansibleInventory) hostGroups(host string) []string {
+	parents := map[string][]string{}
+
+	for name, g := range a.groups {
+		for _, c := range g.children {
+			parents[c] = append(parents[c], name)
+		}
+	}
+
+	groups := map[string]struct{}{}
+
+	var add func(name string)
+
+	add = func(name string) {
+		if _, ok := groups[name]; ok || name == ansibleAllGroup {
+			return
+		}
+
+		groups[name] = struct{}{}
+
+		for _, p := range parents[name] {
+			add(p)
+		}
+	}
+
+	for name, g := range a.groups {
+		if contains(g.hosts, host) {
+			add(name)
+		}
+	}
+
+	if len(groups) == 0 {
+		add(ansibleUngroupedGroup)
+	}
+
+	depths := map[string]int{}
+
+	var depth func(name string, seen map[string]bool) int
+
+	depth = func(name string, seen map[string]bool) int {
+		if d, ok := depths[name]; ok {
+			return d
+		}
+
+		d := 1
+
+		seen[name] = true
+
+		for _, p := range parents[name] {
+			if p != ansibleAllGroup && !seen[p] {
+				if pd := depth(p, seen) + 1; pd > d {
+					d = pd
+				}
+			}
+		}
+
+		depths[name] = d
+
+		return d
+	}
+
+	names := sortedKeys(groups)
+
+	sort.SliceStable(names, func(i, j int) bool {
+		return depth(names[i], map[string]bool{}) < depth(names[j], map[string]bool{})
+	})
+
+	return names
+}
+
+// hostVars returns the vars of the host merged as Ansible does: the vars of the all group
+// are overridden by the vars of the host's groups in the hostGroups order,
+// which are overridden by the host's own vars.
+func (a *ansibleInventory) hostVars(host string, groups []string) map[string]interface{} {
+	vars := map[string]interface{}{}
+
+	for _, g := range append([]string{ansibleAllGroup}, groups...) {
+		for k, v := range a.groups[g].vars {
+			vars[k] = v
+		}
+	}
+
+	for k, v := range a.hosts[host] {
+		vars[k] = v
+	}
+
+	return vars
+}
+
+// toInventory converts the Ansible inventory to the inventory. The hosts become the devices
+// with ansible_host as their address and ansible_network_os mapped onto their platform.
+// ansible_user with the password, become password and private key vars make the credentials,
+// ansible_port makes the transport copying the defaultTransport settings, or the default ones
+// if defaultTransport is nil. The hosts keep their groups, and their vars not specific
+// to ansible are available to the templates. The cmdo_ prefixed vars set the device fields,
+// e.g. cmdo_send_commands sets send-commands. The hosts without ansible_network_os are skipped.
+func (a *ansibleInventory) toInventory(defaultTransport *transports) (*inventory, error) {
+	i := &inventory{
+		Credentials: map[string]*credentials{},
+		Transports:  map[string]*transports{},
+		Devices:     map[string]*device{},
+		Groups:      map[string]*group{},
+	}
+
+	credNames := map[credentials]string{}
+
+	for _, host := range sortedKeys(a.hosts) {
+		groups := a.hostGroups(host)
+		vars := a.hostVars(host, groups)
+
+		d, err := ansibleDevice(host, vars)
+		if err != nil {
+			return nil, fmt.Errorf("ansible host %s: %w", host, err)
+		}
+
+		if d.Platform == "" {
+			log.Warnf("ansible host %s has no ansible_network_os set, skipped", host)
+
+			continue
+		}
+
+		if d.Groups == nil {
+			d.Groups = groups
+		}
+
+		for _, g := range d.Groups {
+			i.Groups[g] = &group{}
+		}
+
+		if c := ansibleCredentials(vars); c != nil && d.Credentials == nil {
+			name, ok := credNames[*c]
+			if !ok {
+				name = uniqueName(i.Credentials, "ansible-"+c.Username)
+				credNames[*c] = name
+				i.Credentials[name] = c
+			}
+
+			d.Credentials = credentialChain{name}
+		}
+
+		if port := int(toInt(vars["ansible_port"])); port != 0 && port != ansibleDefaultPort && d.Transport == "" {
+			// the devices without the transport check the host keys by default
+			t := &transports{StrictKey: true}
+			if defaultTransport != nil {
+				*t = *defaultTransport
+			}
+
+			t.Port = port
+
+			d.Transport = fmt.Sprintf("ansible-port-%d", port)
+			i.Transports[d.Transport] = t
+		}
+
+		i.Devices[host] = d
+	}
+
+	return i, nil
+}
+
+// ansibleDevice returns the device of the host with the vars.
+// The Jinja templates can't be evaluated: the mapped vars holding them are an error,
+// the template vars holding them are skipped. The cmdo_ vars are rendered as the cmdo templates.
+func ansibleDevice(host string, vars map[string]interface{}) (*device, error) {
+	fields := map[string]interface{}{}
+	templateVars := map[string]interface{}{}
+
+	for k, v := range vars {
+		if s, ok := v.(string); ok && strings.HasPrefix(strings.TrimSpace(s), ansibleVaultPrefix) {
+			if strings.HasPrefix(k, "ansible_") || strings.HasPrefix(k, ansibleCmdoVarPrefix) {
+				return nil, fmt.Errorf("%w: %s", errAnsibleVaultValue, k)
+			}
+
+			continue
+		}
+
+		if s, ok := v.(string); ok && strings.Contains(s, jinjaMarker) && !strings.HasPrefix(k, ansibleCmdoVarPrefix) {
+			if contains(ansibleMappedVars, k) {
+				return nil, fmt.Errorf("%w: %s", errAnsibleTemplateValue, k)
+			}
+
+			if !strings.HasPrefix(k, "ansible_") {
+				log.Warnf("ansible host %s var %s is a Jinja template, skipped", host, k)
+			}
+
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(k, ansibleCmdoVarPrefix):
+			fields[strings.ReplaceAll(strings.TrimPrefix(k, ansibleCmdoVarPrefix), "_", "-")] = v
+		case !strings.HasPrefix(k, "ansible_"):
+			templateVars[k] = v
+		}
+	}
+
+	d := &device{}
+
+	b, err := yamlv2.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yamlv2.UnmarshalStrict(b, d); err != nil {
+		return nil, fmt.Errorf("cmdo_ vars: %w", err)
+	}
+
+	if d.Platform == "" {
+		if os := toString(vars["ansible_network_os"]); os != "" {
+			d.Platform = os
+			if p, ok := ansiblePlatforms[os]; ok {
+				d.Platform = p
+			}
+		}
+	}
+
+	if d.Address == "" {
+		d.Address = host
+		if h := toString(vars["ansible_host"]); h != "" {
+			d.Address = h
+		}
+	}
+
+	if len(templateVars) != 0 {
+		d.Vars = mergeMaps(d.Vars, templateVars)
+	}
+
+	return d, nil
+}
+
+// ansibleCredentials returns the credentials set with the ansible vars, or nil if the user isn't set.
+// The password is left empty if it isn't set, for the hosts authenticating with the ssh agent
+// or the keys of the ssh config.
+func ansibleCredentials(vars map[string]interface{}) *credentials {
+	c := &credentials{
+		Username:          toString(vars["ansible_user"]),
+		Password:          firstVar(vars, "ansible_password", "ansible_ssh_pass", "ansible_ssh_password"),
+		SecondaryPassword: firstVar(vars, "ansible_become_password", "ansible_become_pass"),
+		PrivateKey:        firstVar(vars, "ansible_ssh_private_key_file", "ansible_private_key_file"),
+	}
+
+	if c.Username == "" {
+		return nil
+	}
+
+	return c
+}
+
+func firstVar(vars map[string]interface{}, names ...string) string {
+	for _, n := range names {
+		if v := toString(vars[n]); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// uniqueName returns the name, or the name with the lowest numeric suffix not used in m.
+func uniqueName[T any](m map[string]T, name string) string {
+	if _, ok := m[name]; !ok {
+		return name
+	}
+
+	for n := 2; ; n++ {
+		if _, ok := m[fmt.Sprintf("%s-%d", name, n)]; !ok {
+			return fmt.Sprintf("%s-%d", name, n)
+		}
+	}
+}
+
+// readAnsibleInventory reads the Ansible inventory set with --ansible-inventory
+// as the inventory file merged after the other inventory files, using their default transport.
+func (app *appCfg) readAnsibleInventory(files []*inventoryFile) (*inventoryFile, error) {
+	a, err := loadAnsibleInventory(app.ansibleInv)
+	if err != nil {
+		return nil, err
+	}
+
+	// the invalid files are reported by the validation
+	merged := &inventory{}
+	_ = mergeInventory(merged, files)
+
+	i, err := a.toInventory(merged.Transports[defaultName])
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := yamlv2.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &inventoryFile{path: app.ansibleInv, b: b, generated: true}
+
+	doc := &yaml.Node{}
+	if f.parseErr = yaml.Unmarshal(b, doc); f.parseErr == nil && len(doc.Content) != 0 {
+		f.root = resolveAlias(doc.Content[0])
+	}
+
+	return f, nil
+}
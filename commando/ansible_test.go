@@ -0,0 +1,245 @@
+package commando
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExpandAnsibleHosts(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{pattern: "leaf1", want: []string{"leaf1"}},
+		{pattern: "leaf[1:3]", want: []string{"leaf1", "leaf2", "leaf3"}},
+		{pattern: "leaf[01:03]", want: []string{"leaf01", "leaf02", "leaf03"}},
+		{pattern: "leaf[08:10]", want: []string{"leaf08", "leaf09", "leaf10"}},
+		{pattern: "leaf[1:6:2].lab", want: []string{"leaf1.lab", "leaf3.lab", "leaf5.lab"}},
+		{pattern: "rack-[a:c]", want: []string{"rack-a", "rack-b", "rack-c"}},
+		{pattern: "[a:b]-[1:2]", want: []string{"a-1", "a-2", "b-1", "b-2"}},
+		{pattern: "leaf[3:1]", want: nil},
+		{pattern: "leaf[1:3:0]", wantErr: true},
+		{pattern: "leaf[aa:cc]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := expandAnsibleHosts(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAnsibleINI(t *testing.T) {
+	tests := []struct {
+		name       string
+		ini        string
+		wantGroups map[string]*ansibleGroup
+		wantHosts  map[string]map[string]interface{}
+		wantErr    error
+	}{
+		{
+			name: "hosts, vars and children",
+			ini: `
+# comment
+r0 ansible_host=192.0.2.10
+
+[leafs]
+leaf[1:2] ansible_network_os=eos ansible_port=2222 # leafs
+; comment
+
+[leafs:vars]
+ansible_user = "admin"
+
+[fabric:children]
+leafs
+`,
+			wantGroups: map[string]*ansibleGroup{
+				ansibleAllGroup:       {vars: map[string]interface{}{}},
+				ansibleUngroupedGroup: {hosts: []string{"r0"}, vars: map[string]interface{}{}},
+				"leafs":               {hosts: []string{"leaf1", "leaf2"}, vars: map[string]interface{}{"ansible_user": "admin"}},
+				"fabric":              {children: []string{"leafs"}, vars: map[string]interface{}{}},
+			},
+			wantHosts: map[string]map[string]interface{}{
+				"r0":    {"ansible_host": "192.0.2.10"},
+				"leaf1": {"ansible_network_os": "eos", "ansible_port": 2222},
+				"leaf2": {"ansible_network_os": "eos", "ansible_port": 2222},
+			},
+		},
+		{
+			name: "quoted and literal host vars",
+			ini: `[all]
+r1 motd="hello world" enabled=true ratio=0.5 vlans='[10, 20]' name=r1
+`,
+			wantGroups: map[string]*ansibleGroup{
+				ansibleAllGroup:       {hosts: []string{"r1"}, vars: map[string]interface{}{}},
+				ansibleUngroupedGroup: {vars: map[string]interface{}{}},
+			},
+			wantHosts: map[string]map[string]interface{}{
+				"r1": {
+					"motd":    "hello world",
+					"enabled": true,
+					"ratio":   0.5,
+					"vlans":   []interface{}{10, 20},
+					"name":    "r1",
+				},
+			},
+		},
+		{
+			name: "inline comments of children and section headers",
+			ini: `[spine] # dc1 spines
+spine1
+
+[dc1:children]  ; dc1 fabric
+spine  # dc1
+leafs	# dc1 leafs
+
+[leafs]
+`,
+			wantGroups: map[string]*ansibleGroup{
+				ansibleAllGroup:       {vars: map[string]interface{}{}},
+				ansibleUngroupedGroup: {vars: map[string]interface{}{}},
+				"spine":               {hosts: []string{"spine1"}, vars: map[string]interface{}{}},
+				"dc1":                 {children: []string{"spine", "leafs"}, vars: map[string]interface{}{}},
+				"leafs":               {vars: map[string]interface{}{}},
+			},
+			wantHosts: map[string]map[string]interface{}{"spine1": {}},
+		},
+		{
+			name:    "children with several groups on a line",
+			ini:     "[dc1:children]\nspine leafs\n",
+			wantErr: errAnsibleInventory,
+		},
+		{
+			name:    "unknown section type",
+			ini:     "[leafs:hostvars]\n",
+			wantErr: errAnsibleInventory,
+		},
+		{
+			name:    "host var without value",
+			ini:     "r1 ansible_host\n",
+			wantErr: errAnsibleInventory,
+		},
+		{
+			name:    "group var without value",
+			ini:     "[all:vars]\nansible_user\n",
+			wantErr: errAnsibleInventory,
+		},
+		{
+			name:    "unterminated quote",
+			ini:     "r1 motd=\"hello\n",
+			wantErr: errAnsibleInventory,
+		},
+		{
+			name:    "invalid host range",
+			ini:     "leaf[1:3:0]\n",
+			wantErr: errAnsibleInventory,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := parseAnsibleINI([]byte(tt.ini))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(a.groups, tt.wantGroups) {
+				for name, g := range a.groups {
+					t.Errorf("group %s: %+v", name, *g)
+				}
+
+				t.Fatal("groups differ")
+			}
+
+			if !reflect.DeepEqual(a.hosts, tt.wantHosts) {
+				t.Fatalf("got hosts %v, want %v", a.hosts, tt.wantHosts)
+			}
+		})
+	}
+}
+
+func TestAnsibleHostGroupsAndVars(t *testing.T) {
+	ini := `r0
+
+[all:vars]
+ansible_user=all
+site=all
+role=all
+
+[dc1:vars]
+site=dc1
+
+[dc1:children]
+spines
+leafs
+
+[fabric:children]
+leafs
+
+[leafs]
+leaf1 role=host
+
+[leafs:vars]
+role=leafs
+site=leafs
+
+[spines]
+spine1
+
+[spines:vars]
+role=spines
+`
+
+	a, err := parseAnsibleINI([]byte(ini))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host       string
+		wantGroups []string
+		wantVars   map[string]interface{}
+	}{
+		{
+			host:       "leaf1",
+			wantGroups: []string{"dc1", "fabric", "leafs"},
+			wantVars:   map[string]interface{}{"ansible_user": "all", "site": "leafs", "role": "host"},
+		},
+		{
+			host:       "spine1",
+			wantGroups: []string{"dc1", "spines"},
+			wantVars:   map[string]interface{}{"ansible_user": "all", "site": "dc1", "role": "spines"},
+		},
+		{
+			host:       "r0",
+			wantGroups: []string{ansibleUngroupedGroup},
+			wantVars:   map[string]interface{}{"ansible_user": "all", "site": "all", "role": "all"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			groups := a.hostGroups(tt.host)
+			if !reflect.DeepEqual(groups, tt.wantGroups) {
+				t.Fatalf("got groups %q, want %q", groups, tt.wantGroups)
+			}
+
+			if vars := a.hostVars(tt.host, groups); !reflect.DeepEqual(vars, tt.wantVars) {
+				t.Fatalf("got vars %v, want %v", vars, tt.wantVars)
+			}
+		})
+	}
+}
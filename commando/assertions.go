@@ -0,0 +1,186 @@
+package commando
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+const testsReportName = "tests"
+
+type testsFile struct {
+	Tests []*stateTest `yaml:"tests,omitempty"`
+}
+
+// stateTest is a declarative test of the operational state of a device,
+// evaluated on the output of a command parsed with a textfsm template.
+type stateTest struct {
+	Name        string `yaml:"name,omitempty"`
+	deviceScope `yaml:",inline"`
+	// command which output is parsed.
+	Command string `yaml:"command,omitempty"`
+	// path or URL of the textfsm template used to parse the output.
+	Template string `yaml:"template,omitempty"`
+	// assertions on the parsed records, all of them must hold for the test to pass.
+	Assert []*assertion `yaml:"assert,omitempty"`
+}
+
+// assertion is either a condition on a field of the parsed records,
+// or, when Count is set, a condition on the number of records matching the optional field condition.
+type assertion struct {
+	Field string      `yaml:"field,omitempty"`
+	Op    string      `yaml:"op,omitempty"`
+	Value string      `yaml:"value,omitempty"`
+	Match string      `yaml:"match,omitempty"`
+	Count *countCheck `yaml:"count,omitempty"`
+}
+
+// countCheck compares the number of the records with the value.
+type countCheck struct {
+	Op    string `yaml:"op,omitempty"`
+	Value string `yaml:"value,omitempty"`
+}
+
+// loadTests loads and validates the state tests from the f file.
+func loadTests(f string) ([]*stateTest, error) {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	tf := &testsFile{}
+	if err := yaml.UnmarshalStrict(b, tf); err != nil {
+		return nil, err
+	}
+
+	for idx, t := range tf.Tests {
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("test %d (%s): %w", idx, t.Name, err)
+		}
+	}
+
+	return tf.Tests, nil
+}
+
+func (t *stateTest) validate() error {
+	if t.Name == "" || t.Command == "" || t.Template == "" {
+		return fmt.Errorf("%w: name, command and template must be set", errInvalidTest)
+	}
+
+	if len(t.Assert) == 0 {
+		return fmt.Errorf("%w: no assertions defined", errInvalidTest)
+	}
+
+	for _, a := range t.Assert {
+		if a.Count != nil {
+			if _, err := strconv.Atoi(a.Count.Value); err != nil {
+				return fmt.Errorf("%w: count value must be a number", errInvalidTest)
+			}
+
+			if _, ok := fieldOps[a.Count.Op]; !ok {
+				return fmt.Errorf("%w: unknown count operator %q", errInvalidTest, a.Count.Op)
+			}
+
+			if a.Field == "" {
+				continue
+			}
+		}
+
+		if err := a.condition(t.Template).validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *assertion) condition(template string) *fieldCondition {
+	return &fieldCondition{
+		Template: template,
+		Field:    a.Field,
+		Op:       a.Op,
+		Value:    a.Value,
+		Match:    a.Match,
+	}
+}
+
+// evaluate returns the violations of the assertion found in the parsed records.
+func (a *assertion) evaluate(template string, records []map[string]interface{}) ([]string, error) {
+	if a.Count == nil {
+		return a.condition(template).evaluateRecords(records)
+	}
+
+	n := len(records)
+
+	if a.Field != "" {
+		// with the none match the records satisfying the condition are returned
+		c := a.condition(template)
+		c.Match = matchNone
+
+		matching, err := c.evaluateRecords(records)
+		if err != nil {
+			return nil, err
+		}
+
+		n = len(matching)
+	}
+
+	if !compareField(strconv.Itoa(n), a.Count.Op, a.Count.Value) {
+		return []string{fmt.Sprintf("expected count %s %s, got %d", a.Count.Op, a.Count.Value, n)}, nil
+	}
+
+	return nil, nil
+}
+
+// evaluate runs the test against the outputs of the dev device.
+func (t *stateTest) evaluate(dev string, outs map[string]string) *checkResult {
+	res := &checkResult{Device: dev, Check: t.Name}
+
+	out, ok := outs[sanitizeFileName(t.Command)]
+	if !ok {
+		res.Error = "no output collected for " + t.Command
+
+		return res
+	}
+
+	records, err := parseOutput(out, t.Template)
+	if err != nil {
+		res.Error = err.Error()
+
+		return res
+	}
+
+	for _, a := range t.Assert {
+		v, err := a.evaluate(t.Template, records)
+		if err != nil {
+			res.Error = err.Error()
+
+			return res
+		}
+
+		res.Details = append(res.Details, v...)
+	}
+
+	res.Passed = len(res.Details) == 0
+
+	return res
+}
+
+// runTests evaluates the state tests against the outputs of the devices.
+func runTests(tests []*stateTest, devs map[string]*device, outs outputSet) *checkReport {
+	rep := &checkReport{Name: testsReportName}
+
+	for _, name := range sortedKeys(devs) {
+		for _, t := range tests {
+			if !t.applies(devs[name]) {
+				continue
+			}
+
+			rep.Results = append(rep.Results, t.evaluate(name, outs[name]))
+		}
+	}
+
+	return rep
+}
@@ -0,0 +1,139 @@
+package commando
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadTests(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr error
+	}{
+		{
+			name: "valid",
+			file: `tests:
+  - name: bgp
+    command: show bgp summary
+    template: bgp.textfsm
+    assert:
+      - {field: STATE, op: '==', value: Established}
+      - {count: {op: '>=', value: 2}}
+`,
+		},
+		{
+			name:    "no template",
+			file:    "tests:\n  - {name: bgp, command: show bgp summary, assert: [{count: {op: '==', value: 1}}]}\n",
+			wantErr: errInvalidTest,
+		},
+		{
+			name:    "no assertions",
+			file:    "tests:\n  - {name: bgp, command: show bgp summary, template: bgp.textfsm}\n",
+			wantErr: errInvalidTest,
+		},
+		{
+			name: "count value not a number",
+			file: "tests:\n  - {name: bgp, command: show bgp summary, template: bgp.textfsm, " +
+				"assert: [{count: {op: '==', value: two}}]}\n",
+			wantErr: errInvalidTest,
+		},
+		{
+			name: "unknown count operator",
+			file: "tests:\n  - {name: bgp, command: show bgp summary, template: bgp.textfsm, " +
+				"assert: [{count: {op: '=~', value: 2}}]}\n",
+			wantErr: errInvalidTest,
+		},
+		{
+			name: "unknown field operator",
+			file: "tests:\n  - {name: bgp, command: show bgp summary, template: bgp.textfsm, " +
+				"assert: [{field: STATE, op: is, value: Established}]}\n",
+			wantErr: errInvalidFieldCondition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := filepath.Join(t.TempDir(), "tests.yml")
+			if err := os.WriteFile(f, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := loadTests(f); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	tpl := filepath.Join(t.TempDir(), "bgp.textfsm")
+	if err := os.WriteFile(tpl, []byte(bgpPeersTemplate), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	newTest := func(name string, a ...*assertion) *stateTest {
+		return &stateTest{Name: name, Command: "show bgp summary", Template: tpl, Assert: a}
+	}
+
+	scoped := newTest("eos-only", &assertion{Count: &countCheck{Op: "==", Value: "0"}})
+	scoped.Platforms = []string{"arista_eos"}
+
+	tests := []*stateTest{
+		newTest("all-established", &assertion{Field: "STATE", Op: "==", Value: "Established"}),
+		newTest("any-idle", &assertion{Field: "STATE", Op: "==", Value: "Idle", Match: matchAny}),
+		newTest("none-idle", &assertion{Field: "STATE", Op: "==", Value: "Idle", Match: matchNone}),
+		newTest("two-established", &assertion{
+			Field: "STATE", Op: "==", Value: "Established", Count: &countCheck{Op: "==", Value: "2"},
+		}),
+		newTest("unknown-field", &assertion{Field: "UPTIME", Op: "==", Value: "1d"}),
+		scoped,
+	}
+
+	devs := map[string]*device{
+		"r1": {Platform: "arista_eos"},
+		"r2": {Platform: "cisco_iosxr"},
+	}
+
+	outs := outputSet{
+		"r1": {"show-bgp-summary": "10.0.0.1 Established\n10.0.0.3 Idle\n"},
+	}
+
+	type result struct {
+		Device, Check string
+		Passed        bool
+		Error         string
+		Details       []string
+	}
+
+	var got []result
+	for _, r := range runTests(tests, devs, outs).Results {
+		got = append(got, result{r.Device, r.Check, r.Passed, r.Error, r.Details})
+	}
+
+	noOutput := "no output collected for show bgp summary"
+	want := []result{
+		{"r1", "all-established", false, "", []string{"record 1: STATE=Idle"}},
+		{"r1", "any-idle", true, "", nil},
+		{"r1", "none-idle", false, "", []string{"record 1: STATE=Idle"}},
+		{"r1", "two-established", false, "", []string{"expected count == 2, got 1"}},
+		{"r1", "unknown-field", false, `invalid field condition: field "UPTIME" not found in the parsed output`, nil},
+		{"r1", "eos-only", false, "", []string{"expected count == 0, got 2"}},
+		{"r2", "all-established", false, noOutput, nil},
+		{"r2", "any-idle", false, noOutput, nil},
+		{"r2", "none-idle", false, noOutput, nil},
+		{"r2", "two-established", false, noOutput, nil},
+		{"r2", "unknown-field", false, noOutput, nil},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		for _, r := range got {
+			t.Errorf("%+v", r)
+		}
+
+		t.Fatal("test results differ")
+	}
+}
@@ -0,0 +1,325 @@
+package commando
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/scrapli/scrapligo/response"
+	"github.com/scrapli/scrapligocfg"
+	cfgresponse "github.com/scrapli/scrapligocfg/response"
+	cfgutil "github.com/scrapli/scrapligocfg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// cfg operation types.
+const (
+	getConfigOp  = "get-config"
+	loadConfigOp = "load-config"
+	validateOp   = "validate"
+	saveConfigOp = "save-config"
+	rollbackToOp = "rollback"
+	getVersionOp = "get-version"
+	cleanupOp    = "cleanup"
+	exclusiveOp  = "exclusive"
+	sharedOp     = "shared"
+)
+
+// cfgOperationTypes are the types of the cfg operations.
+var cfgOperationTypes = []string{ //nolint:gochecknoglobals
+	getConfigOp, loadConfigOp, validateOp, saveConfigOp, rollbackToOp, getVersionOp, cleanupOp, exclusiveOp, sharedOp,
+}
+
+// names of the outputs of the cfg operations not provided by scrapligocfg.
+const (
+	validateResultOp   = "Validate"
+	saveConfigResultOp = "SaveConfig"
+	rollbackToResultOp = "RollbackConfig"
+	cleanupResultOp    = "Cleanup"
+)
+
+// cfgPlatforms are the platforms supporting the cfg operations.
+var cfgPlatforms = []string{ //nolint:gochecknoglobals
+	"arista_eos", "cisco_iosxe", "cisco_nxos", "cisco_iosxr", "juniper_junos",
+}
+
+// cfgOpPlatforms lists the platforms supporting the cfg operation types
+// which are not supported by all cfgPlatforms.
+var cfgOpPlatforms = map[string][]string{ //nolint:gochecknoglobals
+	// the other platforms have no check of the candidate short of committing it
+	validateOp: {"juniper_junos"},
+	// only iosxr exclusive configuration mode is kept across the load-config operations
+	exclusiveOp: {"cisco_iosxr"},
+	sharedOp:    {"cisco_iosxr"},
+}
+
+// candidateCheckCommands are the config mode commands checking the loaded candidate
+// on the platforms supporting the validate operation.
+var candidateCheckCommands = map[string]string{ //nolint:gochecknoglobals
+	"juniper_junos": "commit check",
+}
+
+// saveConfigCommands are the commands copying the running config to the startup config.
+// The committed config is persistent on the platforms not listed here.
+var saveConfigCommands = map[string]string{ //nolint:gochecknoglobals
+	"arista_eos":  "copy running-config startup-config",
+	"cisco_nxos":  "copy running-config startup-config",
+	"cisco_iosxe": "write memory",
+}
+
+// validate checks the cfg operation of the device running on the platform.
+func (op *cfgOperation) validate(platform string) error {
+	switch op.OperationType {
+	case getConfigOp, saveConfigOp, getVersionOp, cleanupOp:
+	case loadConfigOp, validateOp:
+		if op.Config == "" && op.ConfigFromFile == "" {
+			return fmt.Errorf("%w: %s requires config or config-from-file", errInvalidCfgOperation, op.OperationType)
+		}
+	case rollbackToOp:
+		if op.Checkpoint == "" {
+			return fmt.Errorf("%w: rollback requires checkpoint", errInvalidCfgOperation)
+		}
+
+		if _, err := strconv.Atoi(op.Checkpoint); err != nil && platform == "juniper_junos" {
+			return fmt.Errorf("%w: juniper_junos supports numbered checkpoints only", errInvalidCfgOperation)
+		}
+	case exclusiveOp, sharedOp:
+	default:
+		return fmt.Errorf("%w: unknown type %q", errInvalidCfgOperation, op.OperationType)
+	}
+
+	if !contains(cfgPlatforms, platform) {
+		return fmt.Errorf("%w: cfg operations are not supported on platform %q", errInvalidCfgOperation, platform)
+	}
+
+	if ps, ok := cfgOpPlatforms[op.OperationType]; ok && !contains(ps, platform) {
+		return fmt.Errorf("%w: %s is not supported on platform %q", errInvalidCfgOperation, op.OperationType, platform)
+	}
+
+	return op.validateCommitConfirmed(platform)
+}
+
+// validateCfgOperations checks the cfg operations of the devices.
+func validateCfgOperations(devs map[string]*device) error {
+	for _, name := range sortedKeys(devs) {
+		d := devs[name]
+
+		for idx, op := range d.CfgOperations {
+			if err := op.validate(d.Platform); err != nil {
+				return fmt.Errorf("device %s, cfg operation %d: %w", name, idx, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// withExclusive makes the load-config operation use the exclusive configuration mode,
+// which locks the configuration for the other sessions while the candidate is loaded and committed.
+func withExclusive() cfgutil.Option {
+	return func(o interface{}) error {
+		oo, ok := o.(*cfgutil.OperationOptions)
+		if !ok {
+			return cfgutil.ErrIgnoredOption
+		}
+
+		if oo.Kwargs == nil {
+			oo.Kwargs = map[string]string{}
+		}
+
+		oo.Kwargs["exclusive"] = "true"
+
+		return nil
+	}
+}
+
+// loadCandidate loads the candidate config of the cfg operation.
+func loadCandidate(
+	c *scrapligocfg.Cfg,
+	op *cfgOperation,
+	opts ...cfgutil.Option,
+) (*cfgresponse.Response, error) {
+	if op.ConfigFromFile != "" {
+		return c.LoadConfigFromFile(op.ConfigFromFile, op.Replace, opts...)
+	}
+
+	return c.LoadConfig(op.Config, op.Replace, opts...)
+}
+
+// runCfgValidate loads the candidate, checks it with the platform's check command and aborts it.
+// The validation fails if the device rejects the candidate.
+func runCfgValidate(
+	name, platform string,
+	c *scrapligocfg.Cfg,
+	op *cfgOperation,
+	opts ...cfgutil.Option,
+) (*cfgresponse.Response, error) {
+	r := cfgresponse.NewResponse(validateResultOp, c.Conn.Transport.GetHost())
+
+	lr, err := loadCandidate(c, op, opts...)
+	if err != nil {
+		log.Errorf("load-config operation failed for device %s; error: %+v\n", name, err)
+
+		return nil, err
+	}
+
+	rs := lr.ScrapliResponses
+	failed := lr.Failed
+
+	if failed == nil {
+		cr, checkErr := c.Conn.SendConfig(candidateCheckCommands[platform])
+		if checkErr != nil {
+			log.Errorf("validate operation failed for device %s; error: %+v\n", name, checkErr)
+
+			return nil, checkErr
+		}
+
+		rs = append(rs, cr)
+		failed = cr.Failed
+	}
+
+	if _, err := c.AbortConfig(); err != nil {
+		log.Errorf("abort-config operation failed for device %s; error: %+v\n", name, err)
+
+		return nil, err
+	}
+
+	result := "candidate config is valid"
+	if failed != nil {
+		result = "candidate config is invalid: " + failed.Error()
+	}
+
+	r.Record(rs, result+"\n"+responsesResult(rs))
+
+	if r.Failed != nil {
+		log.Errorf("validate operation failed for device %s; error: %+v\n", name, r.Failed)
+
+		return nil, fmt.Errorf("%w: %v", errValidationFailed, r.Failed)
+	}
+
+	return r, nil
+}
+
+// runCfgSaveConfig copies the running config to the startup config.
+func runCfgSaveConfig(name, platform string, c *scrapligocfg.Cfg) (*cfgresponse.Response, error) {
+	r := cfgresponse.NewResponse(saveConfigResultOp, c.Conn.Transport.GetHost())
+
+	cmd, ok := saveConfigCommands[platform]
+	if !ok {
+		r.Record(nil, fmt.Sprintf("committed config is persistent on %s, nothing to save", platform))
+
+		return r, nil
+	}
+
+	sr, err := c.Conn.SendCommand(cmd)
+	if err != nil {
+		log.Errorf("save-config operation failed for device %s; error: %+v\n", name, err)
+
+		return nil, err
+	}
+
+	r.Record([]*response.Response{sr}, sr.Result)
+
+	if r.Failed != nil {
+		log.Errorf("save-config operation failed for device %s; error: %+v\n", name, r.Failed)
+
+		return nil, r.Failed
+	}
+
+	return r, nil
+}
+
+// runCfgRollback rolls the config back to the named or numbered checkpoint.
+func runCfgRollback(name, platform string, c *scrapligocfg.Cfg, op *cfgOperation) (*cfgresponse.Response, error) {
+	r := cfgresponse.NewResponse(rollbackToResultOp, c.Conn.Transport.GetHost())
+
+	var (
+		rs  []*response.Response
+		err error
+	)
+
+	_, numErr := strconv.Atoi(op.Checkpoint)
+
+	switch platform {
+	case "juniper_junos":
+		var mr *response.MultiResponse
+
+		mr, err = c.Conn.SendConfigs([]string{"rollback " + op.Checkpoint, "commit"})
+		if mr != nil {
+			rs = mr.Responses
+		}
+	case "cisco_iosxr":
+		cmd := "rollback configuration to " + op.Checkpoint
+		if numErr == nil {
+			cmd = "rollback configuration last " + op.Checkpoint
+		}
+
+		rs, err = sendCommand(c, cmd)
+	case "arista_eos":
+		rs, err = sendCommand(c, "configure replace checkpoint:"+op.Checkpoint)
+	case "cisco_nxos":
+		rs, err = sendCommand(c, "rollback running-config checkpoint "+op.Checkpoint)
+	case "cisco_iosxe":
+		rs, err = sendCommand(c, "configure replace "+op.Checkpoint+" force")
+	}
+
+	if err != nil {
+		log.Errorf("rollback operation failed for device %s; error: %+v\n", name, err)
+
+		return nil, err
+	}
+
+	r.Record(rs, responsesResult(rs))
+
+	if r.Failed != nil {
+		log.Errorf("rollback operation failed for device %s; error: %+v\n", name, r.Failed)
+
+		return nil, r.Failed
+	}
+
+	return r, nil
+}
+
+// runCfgCleanup removes the candidates left on the device by the cfg session, such as the EOS
+// config sessions and the NX-OS candidate files, and prepares the session again for the next operations.
+func runCfgCleanup(name string, c *scrapligocfg.Cfg) (*cfgresponse.Response, error) {
+	r := cfgresponse.NewResponse(cleanupResultOp, c.Conn.Transport.GetHost())
+
+	if err := c.Cleanup(); err != nil {
+		log.Errorf("cleanup operation failed for device %s; error: %+v\n", name, err)
+
+		return nil, err
+	}
+
+	if err := c.Prepare(); err != nil {
+		log.Errorf("failed to prepare cfg session for device %s; error: %+v\n", name, err)
+
+		return nil, err
+	}
+
+	r.Record(nil, "cfg session cleaned up")
+
+	return r, nil
+}
+
+func sendCommand(c *scrapligocfg.Cfg, cmd string) ([]*response.Response, error) {
+	r, err := c.Conn.SendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*response.Response{r}, nil
+}
+
+// responsesResult joins the results of the scrapligo responses.
+func responsesResult(rs []*response.Response) string {
+	results := make([]string, 0, len(rs))
+
+	for _, r := range rs {
+		if strings.TrimSpace(r.Result) != "" {
+			results = append(results, r.Result)
+		}
+	}
+
+	return strings.Join(results, "\n")
+}
@@ -0,0 +1,88 @@
+package commando
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCfgOperationValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform string
+		op       *cfgOperation
+		wantErr  error
+	}{
+		{"get-config", "cisco_iosxe", &cfgOperation{OperationType: getConfigOp}, nil},
+		{"cleanup", "arista_eos", &cfgOperation{OperationType: cleanupOp}, nil},
+		{"save-config", "cisco_nxos", &cfgOperation{OperationType: saveConfigOp}, nil},
+		{"get-version", "juniper_junos", &cfgOperation{OperationType: getVersionOp}, nil},
+		{"load-config", "arista_eos", &cfgOperation{OperationType: loadConfigOp, Config: "hostname r1"}, nil},
+		{
+			"load-config without config", "arista_eos",
+			&cfgOperation{OperationType: loadConfigOp}, errInvalidCfgOperation,
+		},
+		{"validate on junos", "juniper_junos", &cfgOperation{OperationType: validateOp, ConfigFromFile: "c.cfg"}, nil},
+		{
+			"validate on eos", "arista_eos",
+			&cfgOperation{OperationType: validateOp, Config: "hostname r1"}, errInvalidCfgOperation,
+		},
+		{
+			"validate without config", "juniper_junos",
+			&cfgOperation{OperationType: validateOp}, errInvalidCfgOperation,
+		},
+		{"rollback to a named checkpoint", "arista_eos", &cfgOperation{OperationType: rollbackToOp, Checkpoint: "pre"}, nil},
+		{
+			"rollback to a numbered checkpoint", "juniper_junos",
+			&cfgOperation{OperationType: rollbackToOp, Checkpoint: "1"}, nil,
+		},
+		{
+			"rollback to a named checkpoint on junos", "juniper_junos",
+			&cfgOperation{OperationType: rollbackToOp, Checkpoint: "pre"}, errInvalidCfgOperation,
+		},
+		{"rollback without checkpoint", "arista_eos", &cfgOperation{OperationType: rollbackToOp}, errInvalidCfgOperation},
+		{"exclusive on iosxr", "cisco_iosxr", &cfgOperation{OperationType: exclusiveOp}, nil},
+		{"shared on iosxr", "cisco_iosxr", &cfgOperation{OperationType: sharedOp}, nil},
+		{"exclusive on junos", "juniper_junos", &cfgOperation{OperationType: exclusiveOp}, errInvalidCfgOperation},
+		{"unknown type", "arista_eos", &cfgOperation{OperationType: "lock"}, errInvalidCfgOperation},
+		{"unsupported platform", "nokia_srl", &cfgOperation{OperationType: getConfigOp}, errInvalidCfgOperation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op.validate(tt.platform); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCfgOperations(t *testing.T) {
+	devs := map[string]*device{
+		"r1": {Platform: "cisco_iosxr", CfgOperations: []*cfgOperation{
+			{OperationType: exclusiveOp},
+			{OperationType: loadConfigOp, Config: "hostname r1", Commit: true},
+			{OperationType: sharedOp},
+			{OperationType: cleanupOp},
+		}},
+		"r2": {Platform: "arista_eos", CfgOperations: []*cfgOperation{
+			{OperationType: getConfigOp},
+			{OperationType: exclusiveOp},
+		}},
+	}
+
+	err := validateCfgOperations(devs)
+	if !errors.Is(err, errInvalidCfgOperation) {
+		t.Fatalf("got error %v, want %v", err, errInvalidCfgOperation)
+	}
+
+	want := `device r2, cfg operation 1: invalid cfg operation: exclusive is not supported on platform "arista_eos"`
+	if err.Error() != want {
+		t.Fatalf("got error %q, want %q", err, want)
+	}
+
+	delete(devs, "r2")
+
+	if err := validateCfgOperations(devs); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,318 @@
+package commando
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scrapli/scrapligo/driver/network"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	preChangeDir   = "pre"
+	postChangeDir  = "post"
+	changeDir      = "change"
+	changeDiffFile = "change-diff.txt"
+)
+
+// changeResult holds the outputs of the checks captured before and after the change.
+type changeResult struct {
+	name string
+	pre  map[string]string // outputs of the checks before the change
+	post map[string]string // outputs of the checks after the change
+	resp []interface{}     // responses of the cfg operations
+	err  error
+}
+
+// runChange runs the change workflow: the check commands are run before the change,
+// then cfg operations and configs are applied, and after the settle time the checks are run again.
+// The outputs captured before and after the change are compared per device.
+func (app *appCfg) runChange() error {
+	i := &inventory{}
+	if err := app.loadInventory(i); err != nil {
+		return err
+	}
+
+	if app.testsFile != "" {
+		var err error
+		if app.tests, err = loadTests(app.testsFile); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if app.tx, err = app.newTxCoordinator(i.Devices); err != nil {
+		return err
+	}
+
+	if app.output == fileOutput {
+		app.outDir = app.fileOutputDir()
+	}
+
+	log.SetOutput(os.Stderr)
+	log.Infof("Started the change with %s settle time...", app.settle)
+
+	results := make([]*changeResult, 0, len(i.Devices))
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	wg.Add(len(i.Devices))
+
+	for n, d := range i.Devices {
+		go func(n string, d *device) {
+			defer wg.Done()
+
+			r := app.changeDevice(n, d)
+
+			mu.Lock()
+			results = append(results, r)
+			mu.Unlock()
+		}(n, d)
+	}
+
+	wg.Wait()
+
+	sort.Slice(results, func(a, b int) bool { return results[a].name < results[b].name })
+
+	pre, post := outputSet{}, outputSet{}
+
+	var failed int
+
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+
+			log.Errorf("change failed for device %s; error: %+v", r.name, r.err)
+		}
+
+		if r.pre != nil {
+			pre[r.name] = r.pre
+		}
+
+		if r.post != nil {
+			post[r.name] = r.post
+		}
+	}
+
+	if app.outDir != "" {
+		if err := app.saveChange(results); err != nil {
+			return err
+		}
+	}
+
+	if err := app.writeChangeDiff(app.tests, i.Devices, pre, post); err != nil {
+		return err
+	}
+
+	var reports []*checkReport
+	if app.tests != nil {
+		reports = append(reports, runTests(app.tests, i.Devices, post))
+	}
+
+	if err := app.writeReports(reports...); err != nil {
+		return err
+	}
+
+	if failed != 0 {
+		return cli.Exit(fmt.Sprintf("change failed on %d device(s)", failed), exitCodeFailed)
+	}
+
+	return nil
+}
+
+// changeDevice runs the change workflow for a single device.
+// The change is not applied if the pre-change checks could not be run.
+func (app *appCfg) changeDevice(name string, d *device) *changeResult {
+	r := &changeResult{name: name}
+
+	// the device must not block the transaction if it fails before reaching the commit
+	defer app.tx.release(name)
+
+	driver, err := app.openCoreConn(name, d)
+	if err != nil {
+		r.err = err
+
+		return r
+	}
+	defer driver.Close()
+
+	if r.pre, err = runChecks(d, driver); err != nil {
+		r.err = fmt.Errorf("pre-change checks failed, change not applied: %w", err)
+
+		return r
+	}
+
+	if r.resp, err = app.runCfg(name, d, driver); err != nil {
+		r.err = err
+	} else if err = runConfigs(name, d, driver); err != nil {
+		r.err = err
+	}
+
+	// post-change checks are run even if the change failed to capture the resulting state
+	time.Sleep(app.settle)
+
+	post, err := runChecks(d, driver)
+	if err != nil {
+		if r.err == nil {
+			r.err = fmt.Errorf("post-change checks failed: %w", err)
+		}
+
+		return r
+	}
+
+	r.post = post
+
+	return r
+}
+
+// runChecks runs the check commands of the device and returns their outputs.
+func runChecks(d *device, driver *network.Driver) (map[string]string, error) {
+	if len(d.Checks) == 0 {
+		return map[string]string{}, nil
+	}
+
+	mr, err := driver.SendCommands(d.Checks)
+	if err != nil {
+		return nil, err
+	}
+
+	return responseOutputs([]interface{}{mr}), nil
+}
+
+// saveChange saves the outputs captured before and after the change
+// along with the outputs of the cfg operations.
+func (app *appCfg) saveChange(results []*changeResult) error {
+	preW := &fileWriter{dir: path.Join(app.outDir, preChangeDir), norm: app.normalizer, keepRaw: app.keepRaw}
+	postW := &fileWriter{dir: path.Join(app.outDir, postChangeDir), norm: app.normalizer, keepRaw: app.keepRaw}
+	changeW := &fileWriter{dir: path.Join(app.outDir, changeDir)}
+
+	for _, r := range results {
+		if r.pre != nil {
+			if err := preW.writeOutputs(r.name, r.pre); err != nil {
+				return err
+			}
+		}
+
+		if r.post != nil {
+			if err := postW.writeOutputs(r.name, r.post); err != nil {
+				return err
+			}
+		}
+
+		if r.resp != nil {
+			if err := changeW.WriteResponse(r.resp, r.name); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Infof("change outputs have been saved to '%s' directory", app.outDir)
+
+	return nil
+}
+
+// writeChangeDiff prints the raw and parsed diffs of the outputs captured before and after the change,
+// and saves them to the output directory for the file output.
+// The text diff is of the normalised outputs, while the templates parse the raw ones,
+// as the normalisation may remove or replace the values they match.
+func (app *appCfg) writeChangeDiff(tests []*stateTest, devs map[string]*device, pre, post outputSet) error {
+	devNames := sortedKeys(post)
+
+	w := io.Writer(os.Stdout)
+
+	if app.outDir != "" {
+		// no device may have written its outputs, e.g. when all of them failed
+		if err := os.MkdirAll(app.outDir, filePermissions); err != nil {
+			return err
+		}
+
+		f, err := os.Create(path.Join(app.outDir, changeDiffFile))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w = io.MultiWriter(os.Stdout, f)
+	}
+
+	diffOutputs(preChangeDir, postChangeDir, app.normalizer.applySet(pre), app.normalizer.applySet(post),
+		devNames...).write(w)
+
+	for _, pd := range parsedDiffs(tests, devs, pre, post) {
+		fmt.Fprintf(w, "\n%s", pd)
+	}
+
+	return nil
+}
+
+// parsedDiffs returns the diffs of the check outputs parsed with the templates of the state tests.
+func parsedDiffs(tests []*stateTest, devs map[string]*device, pre, post outputSet) []string {
+	var diffs []string
+
+	for _, dev := range sortedKeys(post) {
+		seen := map[string]struct{}{}
+
+		for _, t := range tests {
+			d, ok := devs[dev]
+			if !ok || !t.applies(d) {
+				continue
+			}
+
+			f := sanitizeFileName(t.Command)
+			if _, ok := seen[f+t.Template]; ok {
+				continue
+			}
+
+			seen[f+t.Template] = struct{}{}
+
+			preOut, okPre := pre[dev][f]
+			postOut, okPost := post[dev][f]
+
+			if !okPre || !okPost {
+				continue
+			}
+
+			preRecs, errPre := parseOutput(preOut, t.Template)
+			postRecs, errPost := parseOutput(postOut, t.Template)
+
+			if errPre != nil || errPost != nil {
+				continue
+			}
+
+			a, b := recordsString(preRecs), recordsString(postRecs)
+			if a == b {
+				continue
+			}
+
+			diffs = append(diffs, unifiedDiff(
+				path.Join(preChangeDir, dev, f)+" (parsed)",
+				path.Join(postChangeDir, dev, f)+" (parsed)",
+				a, b,
+			))
+		}
+	}
+
+	return diffs
+}
+
+// recordsString returns the parsed records one per line with the fields sorted by name.
+func recordsString(records []map[string]interface{}) string {
+	b := &strings.Builder{}
+
+	for _, rec := range records {
+		fields := make([]string, 0, len(rec))
+		for _, k := range sortedKeys(rec) {
+			fields = append(fields, fmt.Sprintf("%s=%s", k, fieldString(rec[k])))
+		}
+
+		b.WriteString(strings.Join(fields, " ") + "\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package commando
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const bgpUptimeTemplate = `Value NEIGHBOR (\S+)
+Value STATE (\S+)
+
+Start
+  ^${NEIGHBOR}\s+${STATE}\s+\d+:\d+:\d+\s*$$ -> Record
+`
+
+func TestWriteChangeDiff(t *testing.T) {
+	dir := t.TempDir()
+
+	tpl := filepath.Join(dir, "bgp.textfsm")
+	if err := os.WriteFile(tpl, []byte(bgpUptimeTemplate), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	devs := map[string]*device{"r1": {Platform: "arista_eos"}}
+
+	// the uptime replaced by the normalisation is what the template matches the peers by
+	norm, err := newNormalizer([]*normalizeRule{
+		{Replace: []*replaceRule{{Pattern: `\d+:\d+:\d+`, With: "<uptime>"}}},
+	}, devs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &appCfg{normalizer: norm, outDir: filepath.Join(dir, "outputs")}
+
+	tests := []*stateTest{{Name: "bgp", Command: "show bgp summary", Template: tpl}}
+	pre := outputSet{"r1": {"show-bgp-summary": "10.0.0.1 Established 01:02:03\n10.0.0.3 Established 01:02:03\n"}}
+	post := outputSet{"r1": {"show-bgp-summary": "10.0.0.1 Established 01:02:13\n10.0.0.3 Idle 00:00:01\n"}}
+
+	if err := app.writeChangeDiff(tests, devs, pre, post); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(app.outDir, changeDiffFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := string(b)
+
+	for _, want := range []string{
+		// the text diff is of the normalised outputs
+		"-10.0.0.3 Established <uptime>\n+10.0.0.3 Idle <uptime>\n",
+		// the parsed diff is of the raw outputs
+		"--- pre/r1/show-bgp-summary (parsed)\n+++ post/r1/show-bgp-summary (parsed)\n",
+		"-NEIGHBOR=10.0.0.3 STATE=Established\n+NEIGHBOR=10.0.0.3 STATE=Idle\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("change diff doesn't contain %q", want)
+		}
+	}
+
+	if strings.Contains(got, "01:02:13") {
+		t.Error("change diff holds the raw uptime")
+	}
+
+	if strings.Contains(got, "No newline at end of file") {
+		t.Error("parsed records are diffed without the trailing newline")
+	}
+
+	if t.Failed() {
+		t.Logf("change diff:\n%s", got)
+	}
+}
@@ -2,26 +2,26 @@ package commando
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/urfave/cli/v2"
 )
 
 // NewCLI defines the CLI flags and commands.
 func NewCLI() *cli.App {
-	appC := &appCfg{}
+	appC := &appCfg{usedCreds: newUsedCredentials()}
 	flags := []cli.Flag{
-		&cli.StringFlag{
-			Name:        "inventory",
-			Aliases:     []string{"i"},
-			Value:       "inventory.yml",
-			Usage:       "path to the inventory file",
-			Destination: &appC.inventory,
+		&cli.StringSliceFlag{
+			Name:    "inventory",
+			Aliases: []string{"i"},
+			Value:   cli.NewStringSlice(defaultInventory),
+			Usage:   "path to the inventory file. Repeatable, the files are merged",
 		},
 		&cli.StringFlag{
 			Name:        "output",
 			Aliases:     []string{"o"},
 			Value:       "file",
-			Usage:       "output destination. One of: [file, stdout]",
+			Usage:       "output destination. One of: [file, stdout, git]",
 			Destination: &appC.output,
 		},
 		&cli.BoolFlag{
@@ -38,6 +38,16 @@ func NewCLI() *cli.App {
 			Usage:       "filter to select the devices to send commands to",
 			Destination: &appC.devFilter,
 		},
+		&cli.StringSliceFlag{
+			Name:    "group",
+			Aliases: []string{"g"},
+			Usage:   "select the devices of the group. Repeatable, the devices of any of the groups are selected",
+		},
+		&cli.StringFlag{
+			Name:        "ansible-inventory",
+			Usage:       "path to the ansible inventory file (INI or YAML) to read the devices from",
+			Destination: &appC.ansibleInv,
+		},
 		&cli.StringFlag{
 			Name:        "platform",
 			Aliases:     []string{"k"},
@@ -49,7 +59,7 @@ func NewCLI() *cli.App {
 			Name:        "address",
 			Aliases:     []string{"a"},
 			Value:       "",
-			Usage:       "device's address [only for single-node mode]",
+			Usage:       "comma separated addresses of the devices [only for single-node mode]",
 			Destination: &appC.address,
 		},
 		&cli.StringFlag{
@@ -63,15 +73,149 @@ func NewCLI() *cli.App {
 			Name:        "password",
 			Aliases:     []string{"p"},
 			Value:       "",
-			Usage:       "username to use for SSH connection",
+			Usage:       "password to use for SSH connection, prompted for if not set",
+			EnvVars:     []string{"CMDO_PASSWORD"},
 			Destination: &appC.password,
 		},
+		&cli.BoolFlag{
+			Name:        "password-stdin",
+			Value:       false,
+			Usage:       "read the password for SSH connection from stdin",
+			Destination: &appC.passwdStdin,
+		},
 		&cli.StringFlag{
 			Name:        "commands",
 			Aliases:     []string{"c"},
-			Usage:       "commands to send. separated with ::",
+			Usage:       "commands to send. separated with ::, or - to read them from stdin one per line",
 			Destination: &appC.commands,
 		},
+		&cli.StringSliceFlag{
+			Name:  "cmd",
+			Usage: "command to send. Repeatable, added after the --commands",
+		},
+		&cli.StringFlag{
+			Name:        "commands-file",
+			Usage:       "path to the file with the commands to send one per line, added after the --cmd",
+			Destination: &appC.commandsFile,
+		},
+		&cli.StringFlag{
+			Name:        "configs",
+			Usage:       "configs to send. separated with ::",
+			Destination: &appC.configs,
+		},
+		&cli.StringFlag{
+			Name:        "configs-file",
+			Usage:       "path to the file with the configs to send one per line, added after the --configs",
+			Destination: &appC.configsFile,
+		},
+		&cli.StringSliceFlag{
+			Name: "cfg-operation",
+			Usage: "cfg operation to run, set as its type and comma separated fields, " +
+				"e.g. load-config,config-from-file=new.cfg,diff,commit. Repeatable [only for single-node mode]",
+		},
+		&cli.StringFlag{
+			Name:        "private-key",
+			Usage:       "path to the private key to use for SSH connection [only for single-node mode]",
+			Destination: &appC.privateKey,
+		},
+		&cli.IntFlag{
+			Name:        "port",
+			Usage:       "port to connect to [only for single-node mode]",
+			Destination: &appC.port,
+		},
+		&cli.StringFlag{
+			Name:        "transport",
+			Usage:       "transport type. One of: [standard, system, telnet] [only for single-node mode]",
+			Destination: &appC.transportType,
+		},
+		&cli.StringFlag{
+			Name:        "ssh-config-file",
+			Usage:       "path to the ssh config file [only for single-node mode]",
+			Destination: &appC.sshConfigFile,
+		},
+		&cli.BoolFlag{
+			Name:        "no-strict-key",
+			Value:       false,
+			Usage:       "don't check the host keys [only for single-node mode]",
+			Destination: &appC.noStrictKey,
+		},
+		&cli.StringFlag{
+			Name:        "git-repo",
+			Value:       "outputs",
+			Usage:       "path to the git repository to commit the outputs to [only for git output]",
+			Destination: &appC.gitRepo,
+		},
+		&cli.BoolFlag{
+			Name:        "git-branch",
+			Value:       false,
+			Usage:       "commit each run to its own branch [only for git output]",
+			Destination: &appC.gitBranch,
+		},
+		&cli.StringFlag{
+			Name:        "git-base-branch",
+			Value:       "main",
+			Usage:       "branch the per-run branches start from [only for git output with --git-branch]",
+			Destination: &appC.gitBaseBranch,
+		},
+		&cli.BoolFlag{
+			Name:        "git-tag",
+			Value:       false,
+			Usage:       "tag the run's commit when the outputs have changed [only for git output]",
+			Destination: &appC.gitTag,
+		},
+		&cli.BoolFlag{
+			Name:        "diff-previous",
+			Value:       false,
+			Usage:       "show the diff between the outputs of the previous run and this run",
+			Destination: &appC.diffPrev,
+		},
+		&cli.BoolFlag{
+			Name:        "keep-raw",
+			Value:       false,
+			Usage:       "save the raw outputs in addition to the normalised ones",
+			Destination: &appC.keepRaw,
+		},
+		&cli.StringFlag{
+			Name:        "rules",
+			Value:       "",
+			Usage:       "path to the compliance rules file evaluated against the collected outputs",
+			Destination: &appC.rulesFile,
+		},
+		&cli.BoolFlag{
+			Name:        "transaction",
+			Value:       false,
+			Usage:       "commit the load-config operations only if the candidates were loaded on all devices",
+			Destination: &appC.transaction,
+		},
+		&cli.BoolFlag{
+			Name:        "transaction-rollback",
+			Value:       false,
+			Usage:       "roll back the committed devices if the commit fails on any device [only with --transaction]",
+			Destination: &appC.txRollback,
+		},
+		&cli.BoolFlag{
+			Name:        "confirm",
+			Value:       false,
+			Usage:       "review the diffs of the load-config operations and approve the commit per device",
+			Destination: &appC.confirm,
+		},
+		&cli.StringFlag{
+			Name:        "vault-key-file",
+			Usage:       "path to the vault key file or the file holding the vault passphrase, prompted for if not set",
+			Destination: &appC.vaultKeyFile,
+		},
+		&cli.StringFlag{
+			Name:        "tests",
+			Value:       "",
+			Usage:       "path to the state tests file evaluated against the collected outputs",
+			Destination: &appC.testsFile,
+		},
+		&cli.StringFlag{
+			Name:        "report",
+			Value:       consoleReport,
+			Usage:       "format of the check reports. One of: [console, json, junit]",
+			Destination: &appC.reportFormat,
+		},
 	}
 
 	cli.VersionPrinter = showVersion
@@ -81,9 +225,221 @@ func NewCLI() *cli.App {
 		Version: "dev",
 		Usage:   "run commands against network devices",
 		Flags:   flags,
+		// cfg operations use commas to separate their fields and commands may contain commas
+		DisableSliceFlagSeparator: true,
+		Before: func(c *cli.Context) error {
+			appC.inventories = c.StringSlice("inventory")
+			appC.inventorySet = c.IsSet("inventory")
+			// the ansible inventory replaces the default inventory file
+			if c.IsSet("ansible-inventory") && !c.IsSet("inventory") {
+				appC.inventories = nil
+			}
+
+			appC.devGroups = c.StringSlice("group")
+			appC.cmds = c.StringSlice("cmd")
+			appC.cfgOps = c.StringSlice("cfg-operation")
+
+			return nil
+		},
 		Action: func(c *cli.Context) error {
 			return appC.run()
 		},
+		Commands: []*cli.Command{
+			{
+				Name:  "change",
+				Usage: "run the checks before and after applying the configs and compare the outputs",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:        "settle",
+						Value:       10 * time.Second, //nolint:gomnd
+						Usage:       "time to wait after the change before running the post-change checks",
+						Destination: &appC.settle,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return appC.runChange()
+				},
+			},
+			{
+				Name:  "drift",
+				Usage: "compare the running config of the devices with their intended config",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "source",
+						Value:       "running",
+						Usage:       "config source to compare with the intended config",
+						Destination: &appC.driftSource,
+					},
+					&cli.BoolFlag{
+						Name:        "section-aware",
+						Value:       false,
+						Usage:       "compare the configs section by section regardless of the lines order",
+						Destination: &appC.sectionAware,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return appC.runDrift()
+				},
+			},
+			{
+				Name:  "analyze",
+				Usage: "analyze the saved outputs without connecting to the devices",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "from",
+						Usage:       "path to the outputs directory to analyze",
+						Destination: &appC.analyzeFrom,
+					},
+					&cli.StringFlag{
+						Name:        "diff-against",
+						Usage:       "path to the outputs directory to diff the analyzed outputs against",
+						Destination: &appC.diffAgainst,
+					},
+					&cli.BoolFlag{
+						Name:        "drift",
+						Value:       false,
+						Usage:       "compare the saved configs with the intended configs",
+						Destination: &appC.analyzeDrift,
+					},
+					&cli.BoolFlag{
+						Name:        "section-aware",
+						Value:       false,
+						Usage:       "compare the configs section by section regardless of the lines order",
+						Destination: &appC.sectionAware,
+					},
+					// rules and report flags can be set both globally and for the subcommand,
+					// so they don't set the destination to avoid overriding the global values
+					&cli.StringFlag{
+						Name:  "rules",
+						Usage: "path to the compliance rules file evaluated against the outputs",
+					},
+					&cli.StringFlag{
+						Name:  "tests",
+						Usage: "path to the state tests file evaluated against the outputs",
+					},
+					&cli.StringFlag{
+						Name:  "report",
+						Usage: "format of the check reports. One of: [console, json, junit]",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.IsSet("rules") {
+						appC.rulesFile = c.String("rules")
+					}
+
+					if c.IsSet("tests") {
+						appC.testsFile = c.String("tests")
+					}
+
+					if c.IsSet("report") {
+						appC.reportFormat = c.String("report")
+					}
+
+					return appC.runAnalyze()
+				},
+			},
+			{
+				Name:  "validate",
+				Usage: "validate the inventory without connecting to the devices",
+				Action: func(c *cli.Context) error {
+					return appC.runValidate()
+				},
+			},
+			{
+				Name:  "schema",
+				Usage: "print the JSON Schema of the inventory",
+				Action: func(c *cli.Context) error {
+					return appC.runSchema()
+				},
+			},
+			{
+				Name:  "render",
+				Usage: "write the rendered commands and configs of the devices without connecting to them",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "dir",
+						Value:       "rendered",
+						Usage:       "directory to write the per-device rendered files to",
+						Destination: &appC.renderDir,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return appC.runRender()
+				},
+			},
+			{
+				Name:  "vault",
+				Usage: "encrypt, decrypt or edit the inventory files with a passphrase or key",
+				Subcommands: []*cli.Command{
+					{
+						Name:      vaultEncrypt,
+						Usage:     "encrypt the whole file or its credentials section",
+						ArgsUsage: "<file>",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "credentials-only",
+								Usage: "encrypt only the credentials section of the inventory",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return errVaultArgs
+							}
+
+							return appC.runVault(vaultEncrypt, c.Args().First(), c.Bool("credentials-only"))
+						},
+					},
+					{
+						Name:      vaultDecrypt,
+						Usage:     "decrypt the file in place",
+						ArgsUsage: "<file>",
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return errVaultArgs
+							}
+
+							return appC.runVault(vaultDecrypt, c.Args().First(), false)
+						},
+					},
+					{
+						Name:      vaultEdit,
+						Usage:     "decrypt the file, open it in the $EDITOR and encrypt it back",
+						ArgsUsage: "<file>",
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return errVaultArgs
+							}
+
+							return appC.runVault(vaultEdit, c.Args().First(), false)
+						},
+					},
+					{
+						Name:      vaultKeygen,
+						Usage:     "generate the vault key file used with --vault-key-file",
+						ArgsUsage: "<file>",
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return errVaultArgs
+							}
+
+							return runVaultKeygen(c.Args().First())
+						},
+					},
+				},
+			},
+			{
+				Name:      "diff",
+				Usage:     "compare the outputs of two runs",
+				ArgsUsage: "<runA> <runB>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 2 { //nolint:gomnd
+						return errDiffArgs
+					}
+
+					return appC.runDiff(c.Args().Get(0), c.Args().Get(1))
+				},
+			},
+		},
 	}
 
 	return app
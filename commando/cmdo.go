@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/scrapli/scrapligocfg/response"
 
 	"github.com/scrapli/scrapligocfg"
+	cfgutil "github.com/scrapli/scrapligocfg/util"
 
 	"github.com/scrapli/scrapligo/driver/network"
 	log "github.com/sirupsen/logrus"
@@ -34,14 +36,57 @@ var (
 		supportedPlatforms,
 	)
 	errNoUsernameDefined = errors.New("username was not provided. Use --username | -u to set it")
-	errNoPasswordDefined = errors.New("password was not provided. Use --passoword | -p to set it")
+	errNoPasswordDefined = errors.New(
+		"password was not provided. Use --password-stdin, CMDO_PASSWORD env var, --password | -p or run in a terminal to be prompted for it",
+	)
 	errNoCommandsDefined = errors.New(
-		"commands were not provided. Use --commands | -c to set a `::` delimited list of commands to run",
+		"commands were not provided. Use --commands | -c to set a `::` delimited list of commands to run, " +
+			"--cmd, --commands-file or -c - to read them from stdin, " +
+			"or set the configs to send with --configs, --configs-file or --cfg-operation",
 	)
 
 	errInvalidCredentialsName = errors.New("invalid credentials name provided for host")
 	errInvalidTransportsName  = errors.New("invalid transport name provided for host")
 
+	errDiffArgs = errors.New("diff requires exactly two output directories to compare")
+
+	errNoIntendedConfigs     = errors.New("no devices with intended-config defined")
+	errInvalidRule           = errors.New("invalid rule")
+	errInvalidFieldCondition = errors.New("invalid field condition")
+	errNoAnalyzeDir          = errors.New("outputs directory to analyze was not provided. Use --from to set it")
+	errInvalidReportFormat   = errors.New("invalid report format. Report format should be one of: [console, json, junit]")
+	errInvalidTest           = errors.New("invalid test")
+	errMultipleTxOperations  = errors.New(
+		"only one load-config operation with commit is allowed per device in the transaction and confirm modes",
+	)
+	errNotCommitted         = errors.New("candidate config was not committed")
+	errConfigRolledBack     = errors.New("config rolled back")
+	errInvalidDeviceName    = errors.New("device name must be a single path element")
+	errUnknownGroup         = errors.New("unknown group")
+	errRequiredValue        = errors.New("required value is missing")
+	errInvalidCfgOperation  = errors.New("invalid cfg operation")
+	errValidationFailed     = errors.New("candidate config validation failed")
+	errSecretReference      = errors.New("failed to resolve secret reference")
+	errVaultDecrypt         = errors.New("vault decryption failed, wrong passphrase or corrupted data")
+	errVaultPassMismatch    = errors.New("vault passphrases do not match")
+	errNoVaultPass          = errors.New("vault passphrase is empty")
+	errInvalidVaultKey      = errors.New("invalid vault key file, generate it with the vault keygen command")
+	errVaultKeyRequired     = errors.New("data is encrypted with the vault key, set --vault-key-file to the key file")
+	errVaultPassRequired    = errors.New("data is encrypted with the passphrase, but --vault-key-file holds the key")
+	errNoTerminal           = errors.New("can't prompt for the secret, stdin is not a terminal")
+	errAlreadyVaulted       = errors.New("file is already vault encrypted")
+	errNotVaulted           = errors.New("file is not vault encrypted")
+	errNoCredentialsSection = errors.New("file has no credentials section to encrypt")
+	errVaultArgs            = errors.New("vault commands take the path to the file to process")
+	errIncludeNotFound      = errors.New("included file does not exist")
+	errAnsibleInventory     = errors.New("invalid ansible inventory")
+	errAnsibleVaultValue    = errors.New("ansible vault encrypted values are not supported")
+	errAnsibleTemplateValue = errors.New("ansible Jinja templated values are not supported")
+	errStdinInUse           = errors.New(
+		"stdin can't be used for the commands with --password-stdin, --confirm, or the password " +
+			"and vault passphrase prompts, use --commands-file instead",
+	)
+
 	errInvalidTransport = errors.New(
 		"invalid transport name provided in inventory. Transport should be one of: [standard, system]",
 	)
@@ -50,25 +95,45 @@ var (
 const (
 	fileOutput   = "file"
 	stdoutOutput = "stdout"
+	gitOutput    = "git"
 	defaultName  = "default"
+
+	// defaultInventory is the inventory file read when --inventory is not set.
+	defaultInventory = "inventory.yml"
+
+	// stdinArg is the flag value meaning the value is read from stdin.
+	stdinArg = "-"
 )
 
 type inventory struct {
+	// paths or glob patterns of the inventory files to include, relative to the including file.
+	Include     []string                `yaml:"include,omitempty"`
 	Credentials map[string]*credentials `yaml:"credentials,omitempty"`
 	Transports  map[string]*transports  `yaml:"transports,omitempty"`
 	Devices     map[string]*device      `yaml:"devices,omitempty"`
+	Normalize   []*normalizeRule        `yaml:"normalize,omitempty"`
+	Groups      map[string]*group       `yaml:"groups,omitempty"`
+	Vars        map[string]interface{}  `yaml:"vars,omitempty"`
 }
 
 type device struct {
 	Platform             string          `yaml:"platform,omitempty"`
 	Address              string          `yaml:"address,omitempty"`
-	Credentials          string          `yaml:"credentials,omitempty"`
+	Credentials          credentialChain `yaml:"credentials,omitempty"`
 	Transport            string          `yaml:"transport,omitempty"`
 	SendCommands         []string        `yaml:"send-commands,omitempty"`
 	SendCommandsFromFile string          `yaml:"send-commands-from-file,omitempty"`
 	SendConfigs          []string        `yaml:"send-configs,omitempty"`
 	SendConfigsFromFile  string          `yaml:"send-configs-from-file,omitempty"`
 	CfgOperations        []*cfgOperation `yaml:"cfg-operations,omitempty"`
+	IntendedConfig       string          `yaml:"intended-config,omitempty"`
+	Tags                 []string        `yaml:"tags,omitempty"`
+	Checks               []string        `yaml:"checks,omitempty"`
+	// groups the device belongs to, their vars are applied in the listed order.
+	Groups []string               `yaml:"groups,omitempty"`
+	Vars   map[string]interface{} `yaml:"vars,omitempty"`
+
+	vars map[string]interface{} // vars merged from the inventory, groups and device
 }
 
 type credentials struct {
@@ -93,49 +158,156 @@ type cfgOperation struct {
 	Replace        bool   `yaml:"replace,omitempty"`
 	Diff           bool   `yaml:"diff,omitempty"`
 	Commit         bool   `yaml:"commit,omitempty"`
+	// restore the config saved before the commit when the post-checks fail
+	RollbackOnFailure bool `yaml:"rollback-on-failure,omitempty"`
+	// time to wait after the commit before running the post-checks
+	Settle time.Duration `yaml:"settle,omitempty"`
+	// name or number of the checkpoint to roll back to with the rollback operation
+	Checkpoint string `yaml:"checkpoint,omitempty"`
+	// commit with the timeout after which the device rolls the commit back unless it is confirmed
+	CommitConfirmed time.Duration `yaml:"commit-confirmed,omitempty"`
 }
 
 type appCfg struct {
-	inventory   string                  // path to inventory file
-	credentials map[string]*credentials // credentials loaded from inventory
-	transports  map[string]*transports  // transports loaded from inventory
-	output      string                  // output mode
-	timestamp   bool                    // append timestamp to output dir
-	outDir      string                  // output directory path
-	devFilter   string                  // pattern
-	platform    string                  // platform name
-	address     string                  // device address
-	username    string                  // ssh username
-	password    string                  // ssh password
-	commands    string                  // commands to send
+	inventories   []string                // paths to the inventory files
+	credentials   map[string]*credentials // credentials loaded from inventory
+	transports    map[string]*transports  // transports loaded from inventory
+	output        string                  // output mode
+	timestamp     bool                    // append timestamp to output dir
+	outDir        string                  // output directory path
+	devFilter     string                  // pattern
+	devGroups     []string                // groups selecting the devices
+	platform      string                  // platform name
+	address       string                  // comma separated device addresses
+	username      string                  // ssh username
+	password      string                  // ssh password
+	commands      string                  // commands to send
+	cmds          []string                // commands set one per --cmd flag
+	commandsFile  string                  // path to the file with the commands to send
+	gitRepo       string                  // path to the git repository for git output
+	gitBranch     bool                    // create a branch per run in git output
+	gitBaseBranch string                  // branch the per-run branches start from in git output
+	gitTag        bool                    // tag the run's commit on changes in git output
+	diffPrev      bool                    // diff the outputs against the previous run
+	outputs       outputSet               // outputs collected during the run
+	rawOutputs    outputSet               // outputs collected during the run before the normalisation
+	failures      map[string]error        // errors of the devices the run failed for
+	normalizer    *normalizer             // normalisation rules loaded from inventory
+	keepRaw       bool                    // keep raw outputs next to the normalised ones
+	reportFormat  string                  // format of the check reports
+	driftSource   string                  // config source to compare with the intended config
+	sectionAware  bool                    // compare configs section-aware in drift mode
+	rulesFile     string                  // path to the compliance rules file
+	testsFile     string                  // path to the state tests file
+	analyzeFrom   string                  // outputs directory to analyze offline
+	diffAgainst   string                  // outputs directory to diff the analyzed outputs against
+	analyzeDrift  bool                    // check the drift of the saved configs offline
+	settle        time.Duration           // time to wait after the change before the post-checks
+	tests         []*stateTest            // state tests loaded from the tests file
+	transaction   bool                    // commit the load-config operations in a transaction
+	txRollback    bool                    // roll back the committed devices if the transaction commit fails
+	tx            *txCoordinator          // transaction coordinator, nil if transaction and confirm modes are off
+	confirm       bool                    // ask the operator to approve the candidate diffs before the commit
+	renderDir     string                  // directory the rendered configs are written to
+	vaultKeyFile  string                  // path to the file holding the vault passphrase
+	vault         *vaultSecret            // vault passphrase or key, read once
+	usedCreds     *usedCredentials        // credentials the devices were connected with
+	passwdStdin   bool                    // read the password from stdin
+	port          int                     // ssh port in the single-node mode
+	privateKey    string                  // path to the ssh private key in the single-node mode
+	transportType string                  // transport type in the single-node mode
+	sshConfigFile string                  // path to the ssh config file in the single-node mode
+	noStrictKey   bool                    // don't check the host keys in the single-node mode
+	configs       string                  // configs to send
+	configsFile   string                  // path to the file with the configs to send
+	cfgOps        []string                // cfg operations in the single-node mode
+	ansibleInv    string                  // path to the ansible inventory
+	inventorySet  bool                    // inventory files are set with --inventory
 }
 
 type respTuple struct {
 	name string
 	resp []interface{}
+	err  error
 }
 
-// run runs the commando.
-func (app *appCfg) run() error {
-	i := &inventory{}
+// loadInventory loads the inventory from the inventory file,
+// or from the cli flags in the single-node mode.
+// The secrets of the inventory credentials are resolved, as the devices are connected to afterwards.
+func (app *appCfg) loadInventory(i *inventory) error {
 	// start bulk commands routine
 	if app.address == "" {
 		if err := app.loadInventoryFromYAML(i); err != nil {
 			return err
 		}
-	} else { // else we run commands against a single device
-		if err := app.loadInventoryFromFlags(i); err != nil {
+
+		return app.resolveCredentials(i.Credentials, i.Devices)
+	}
+
+	// else we run commands against a single device
+	return app.loadInventoryFromFlags(i)
+}
+
+// run runs the commando.
+func (app *appCfg) run() error {
+	i := &inventory{}
+	if err := app.loadInventory(i); err != nil {
+		return err
+	}
+
+	var rules []*rule
+
+	if app.rulesFile != "" {
+		var err error
+		if rules, err = loadRules(app.rulesFile); err != nil {
+			return err
+		}
+	}
+
+	if app.testsFile != "" {
+		var err error
+		if app.tests, err = loadTests(app.testsFile); err != nil {
 			return err
 		}
 	}
 
 	rw := app.newResponseWriter(app.output)
 
+	// the per-run branch starts from the base branch, which holds the previous outputs as well
+	if gw, ok := rw.(*gitWriter); ok {
+		if err := gw.checkoutBase(); err != nil {
+			return err
+		}
+	}
+
+	// previous outputs are loaded before the writer gets a chance to overwrite them
+	var prevDir string
+
+	var prevOutputs outputSet
+
+	if app.diffPrev {
+		var err error
+		if prevDir, prevOutputs, err = app.previousOutputs(); err != nil {
+			return err
+		}
+
+		prevOutputs = app.normalizer.applySet(prevOutputs)
+	}
+
+	var err error
+	if app.tx, err = app.newTxCoordinator(i.Devices); err != nil {
+		return err
+	}
+
+	app.outputs = outputSet{}
+	app.rawOutputs = outputSet{}
+	app.failures = map[string]error{}
+
 	respCh := make(chan respTuple)
 
 	doneCh := make(chan interface{})
 
-	if app.output == fileOutput {
+	if app.output == fileOutput || app.output == gitOutput {
 		log.SetOutput(os.Stderr)
 		log.Infof("Started sending commands and capturing outputs...")
 	}
@@ -153,11 +325,70 @@ func (app *appCfg) run() error {
 
 	doneCh <- nil
 
+	var reports []*checkReport
+
+	// the normalisation rules must not hide the violations and the state being tested
+	if rules != nil {
+		reports = append(reports, checkCompliance(rules, i.Devices, app.rawOutputs))
+	}
+
+	if app.tests != nil {
+		reports = append(reports, runTests(app.tests, i.Devices, app.rawOutputs))
+	}
+
+	// reports and manifest are saved before finalizing, so that the git output commits them as well
+	if err := app.saveReports(reports...); err != nil {
+		return err
+	}
+
+	manifest := app.newRunManifest(i.Devices)
+	if err := app.saveManifest(manifest); err != nil {
+		return err
+	}
+
+	if gw, ok := rw.(*gitWriter); ok {
+		gw.fallbacks = manifest.fallbacks()
+	}
+
+	if f, ok := rw.(finalizer); ok {
+		if err := f.Finalize(); err != nil {
+			return err
+		}
+	}
+
 	if app.output == fileOutput {
 		log.Infof("outputs have been saved to '%s' directory", app.outDir)
 	}
 
-	return nil
+	if app.diffPrev {
+		app.diffPrevious(prevDir, prevOutputs)
+	}
+
+	repErr := app.printReports(reports...)
+
+	// the rolled back configs fail the run regardless of the checks results
+	if err := app.rolledBackError(); err != nil {
+		return err
+	}
+
+	return repErr
+}
+
+// diffPrevious prints the diff between the outputs of the previous run
+// and the outputs of the devices collected during this run.
+func (app *appCfg) diffPrevious(prevDir string, prev outputSet) {
+	if prevDir == "" {
+		log.Warn("no previous outputs found to diff against")
+
+		return
+	}
+
+	devs := make([]string, 0, len(app.outputs))
+	for d := range app.outputs {
+		devs = append(devs, d)
+	}
+
+	diffOutputs(prevDir, "current", prev, app.outputs, devs...).write(os.Stdout)
 }
 
 func runCfgGetConfig(
@@ -180,45 +411,105 @@ func runCfgGetConfig(
 	return r, nil
 }
 
-func runCfgLoadConfig(name string, c *scrapligocfg.Cfg, op *cfgOperation) ([]interface{}, error) {
+func (app *appCfg) runCfgLoadConfig(
+	name string,
+	d *device,
+	c *scrapligocfg.Cfg,
+	op *cfgOperation,
+	opts ...cfgutil.Option,
+) ([]interface{}, error) {
 	var responses []interface{}
 
 	var r *response.Response
 
 	var err error
 
-	if op.Config != "" {
-		_, err = c.LoadConfig(op.Config, op.Replace)
-	} else if op.ConfigFromFile != "" {
-		_, err = c.LoadConfigFromFile(op.ConfigFromFile, op.Replace)
+	txMode := op.Commit && app.tx.participates(name)
+	if txMode {
+		// the device must not block the transaction if it fails before voting
+		defer app.tx.release(name)
 	}
 
+	// running config is saved to be restored if the post-checks or the transaction fail after the commit
+	var saved string
+
+	if op.RollbackOnFailure && len(app.postCheckTests(d)) == 0 {
+		log.Warnf("rollback-on-failure is set for device %s without the state tests of its checks, "+
+			"the config is never rolled back", name)
+	}
+
+	if op.Commit && (op.RollbackOnFailure || (txMode && app.txRollback)) {
+		sr, saveErr := c.GetConfig("running")
+		if saveErr != nil {
+			log.Errorf("failed to save running config for device %s; error: %+v\n", name, saveErr)
+
+			return nil, saveErr
+		}
+
+		saved = sr.Result
+	}
+
+	_, err = loadCandidate(c, op, opts...)
 	if err != nil {
 		log.Errorf("load-config operation failed for device %s; error: %+v\n", name, err)
 
 		return nil, err
 	}
 
-	if op.Diff {
-		dr, diffErr := c.DiffConfig("running")
-		if diffErr != nil {
-			log.Errorf("diff-config operation failed for device %s; error: %+v\n", name, diffErr)
+	var diff *response.DiffResponse
+
+	// the operator reviews the diffs in the confirm mode
+	if op.Diff || (txMode && app.confirm) {
+		diff, err = c.DiffConfig("running")
+		if err != nil {
+			log.Errorf("diff-config operation failed for device %s; error: %+v\n", name, err)
 
-			return nil, diffErr
+			return nil, err
 		}
 
-		responses = append(responses, dr)
+		if op.Diff {
+			responses = append(responses, diff)
+		}
 	}
 
-	if op.Commit {
-		r, err = c.CommitConfig()
+	if txMode {
+		var txResponses []interface{}
+
+		var committed bool
+
+		txResponses, committed, err = app.runTxCommit(name, c, diff, saved,
+			func() (*response.Response, error) { return app.commitCandidate(name, d, c, op, opts...) }, opts...)
+
+		responses = append(responses, txResponses...)
+
 		if err != nil {
-			log.Errorf("commit-config operation failed for device %s; error: %+v\n", name, err)
+			return rolledBackResponses(responses, err)
+		}
 
-			return nil, err
+		if committed && op.RollbackOnFailure {
+			rr, rbErr := app.rollbackOnFailure(name, d, c, op, saved, opts...)
+			if rbErr != nil {
+				return rolledBackResponses(append(responses, rr), rbErr)
+			}
+		}
+
+		return responses, nil
+	}
+
+	if op.Commit {
+		r, err = app.commitCandidate(name, d, c, op, opts...)
+		if err != nil {
+			return rolledBackResponses(append(responses, r), err)
 		}
 
 		responses = append(responses, r)
+
+		if op.RollbackOnFailure {
+			rr, rbErr := app.rollbackOnFailure(name, d, c, op, saved, opts...)
+			if rbErr != nil {
+				return rolledBackResponses(append(responses, rr), rbErr)
+			}
+		}
 	} else {
 		_, err = c.AbortConfig()
 		if err != nil {
@@ -231,11 +522,8 @@ func runCfgLoadConfig(name string, c *scrapligocfg.Cfg, op *cfgOperation) ([]int
 	return responses, nil
 }
 
-func runCfg(name string, d *device, driver *network.Driver) ([]interface{}, error) {
-	if d.CfgOperations == nil {
-		return nil, nil
-	}
-
+// newCfgSession creates and prepares the cfg session over the opened driver.
+func newCfgSession(name string, d *device, driver *network.Driver) (*scrapligocfg.Cfg, error) {
 	c, err := scrapligocfg.NewCfg(driver, d.Platform)
 	if err != nil {
 		log.Errorf("failed to create cfg connection for device %s; error: %+v\n", name, err)
@@ -250,32 +538,101 @@ func runCfg(name string, d *device, driver *network.Driver) ([]interface{}, erro
 		return nil, err
 	}
 
+	return c, nil
+}
+
+func (app *appCfg) runCfg(name string, d *device, driver *network.Driver) ([]interface{}, error) {
+	if d.CfgOperations == nil {
+		return nil, nil
+	}
+
+	c, err := newCfgSession(name, d, driver)
+	if err != nil {
+		return nil, err
+	}
+
 	var responses []interface{}
 
+	// options of the load-config operations, set by the exclusive and shared operations
+	var loadOpts []cfgutil.Option
+
 	for _, op := range d.CfgOperations {
+		var (
+			r     *response.Response
+			opErr error
+		)
+
 		switch op.OperationType {
-		case "get-config":
-			r, opErr := runCfgGetConfig(name, c, op)
-			if opErr != nil {
-				return nil, opErr
+		case getConfigOp:
+			r, opErr = runCfgGetConfig(name, c, op)
+		case loadConfigOp:
+			lr, loadErr := app.runCfgLoadConfig(name, d, c, op, loadOpts...)
+			if loadErr != nil {
+				return rolledBackResponses(append(responses, lr...), loadErr)
 			}
 
-			responses = append(responses, r)
-		case "load-config":
-			r, opErr := runCfgLoadConfig(name, c, op)
+			responses = append(responses, lr...)
+
+			continue
+		case validateOp:
+			r, opErr = runCfgValidate(name, d.Platform, c, op, loadOpts...)
+		case saveConfigOp:
+			r, opErr = runCfgSaveConfig(name, d.Platform, c)
+		case rollbackToOp:
+			r, opErr = runCfgRollback(name, d.Platform, c, op)
+		case getVersionOp:
+			r, opErr = c.GetVersion()
 			if opErr != nil {
-				return nil, opErr
+				log.Errorf("get-version operation failed for device %s; error: %+v\n", name, opErr)
 			}
+		case cleanupOp:
+			r, opErr = runCfgCleanup(name, c)
+		case exclusiveOp:
+			loadOpts = []cfgutil.Option{withExclusive()}
 
-			responses = append(responses, r...)
+			continue
+		case sharedOp:
+			loadOpts = nil
+
+			continue
 		default:
 			log.Errorf("invalid operation type '%s' for device %s\n", op.OperationType, name)
+
+			continue
 		}
+
+		if opErr != nil {
+			return nil, opErr
+		}
+
+		responses = append(responses, r)
 	}
 
 	return responses, nil
 }
 
+// commitCandidate commits the loaded candidate, with the commit-confirmed timeout if set.
+func (app *appCfg) commitCandidate(
+	name string,
+	d *device,
+	c *scrapligocfg.Cfg,
+	op *cfgOperation,
+	opts ...cfgutil.Option,
+) (*response.Response, error) {
+	if op.CommitConfirmed != 0 {
+		return app.runCommitConfirmed(name, d, c, op, opts...)
+	}
+
+	r, err := c.CommitConfig()
+	if err != nil {
+		log.Errorf("commit-config operation failed for device %s; error: %+v\n", name, err)
+
+		return nil, err
+	}
+
+	return r, nil
+}
+
 func runConfigs(name string, d *device, driver *network.Driver) error {
 	// when sending configs we do not print any responses, as typically configs do not produce any output
 	if d.SendConfigsFromFile != "" {
@@ -331,11 +688,15 @@ func (app *appCfg) runOperations(
 	name string,
 	d *device,
 	rCh chan<- respTuple) {
+	// the device must not block the transaction if it fails before reaching the commit
+	defer app.tx.release(name)
+
 	driver, err := app.openCoreConn(name, d)
 	if err != nil {
 		rCh <- respTuple{
 			name: name,
 			resp: nil,
+			err:  err,
 		}
 
 		return
@@ -343,11 +704,13 @@ func (app *appCfg) runOperations(
 
 	var responses []interface{}
 
-	cfgResponses, err := runCfg(name, d, driver)
+	cfgResponses, err := app.runCfg(name, d, driver)
 	if err != nil {
+		// the responses recording the rollback are still written
 		rCh <- respTuple{
 			name: name,
-			resp: nil,
+			resp: cfgResponses,
+			err:  err,
 		}
 
 		return
@@ -360,6 +723,7 @@ func (app *appCfg) runOperations(
 		rCh <- respTuple{
 			name: name,
 			resp: nil,
+			err:  err,
 		}
 
 		return
@@ -370,6 +734,7 @@ func (app *appCfg) runOperations(
 		rCh <- respTuple{
 			name: name,
 			resp: nil,
+			err:  err,
 		}
 
 		return
@@ -394,6 +759,15 @@ func (app *appCfg) outputResult(
 		case <-doneCh:
 			return
 		case r := <-rCh:
+			if r.err != nil {
+				app.failures[r.name] = r.err
+			}
+
+			if r.resp != nil {
+				app.rawOutputs[r.name] = responseOutputs(r.resp)
+				app.outputs[r.name] = app.normalizer.applyDevice(r.name, app.rawOutputs[r.name])
+			}
+
 			if err := rw.WriteResponse(r.resp, r.name); err != nil {
 				log.Errorf("error while writing the response: %v", err)
 
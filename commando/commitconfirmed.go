@@ -0,0 +1,138 @@
+package commando
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/scrapli/scrapligo/driver/opoptions"
+	"github.com/scrapli/scrapligo/response"
+	"github.com/scrapli/scrapligocfg"
+	cfgresponse "github.com/scrapli/scrapligocfg/response"
+	cfgutil "github.com/scrapli/scrapligocfg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	commitConfirmedOp = "CommitConfirmed"
+
+	// minimal confirm timeout accepted by iosxr.
+	iosxrMinConfirmTimeout = 30 * time.Second
+)
+
+// commitConfirmedPlatforms are the platforms supporting the commit with the automatic rollback.
+var commitConfirmedPlatforms = []string{"cisco_iosxr", "juniper_junos"} //nolint:gochecknoglobals
+
+// validateCommitConfirmed checks the commit-confirmed settings of the load-config operation.
+func (op *cfgOperation) validateCommitConfirmed(platform string) error {
+	if op.CommitConfirmed == 0 {
+		return nil
+	}
+
+	switch {
+	case op.OperationType != loadConfigOp || !op.Commit:
+		return fmt.Errorf("%w: commit-confirmed requires load-config with commit", errInvalidCfgOperation)
+	case !contains(commitConfirmedPlatforms, platform):
+		return fmt.Errorf("%w: commit-confirmed is not supported on platform %q", errInvalidCfgOperation, platform)
+	case op.RollbackOnFailure:
+		return fmt.Errorf("%w: commit-confirmed already rolls back when the post-checks fail, "+
+			"rollback-on-failure can't be used with it", errInvalidCfgOperation)
+	case op.Settle >= op.CommitConfirmed:
+		return fmt.Errorf("%w: settle must be shorter than the commit-confirmed timeout", errInvalidCfgOperation)
+	}
+
+	return nil
+}
+
+// commitConfirmedCommand returns the platform's command committing the candidate,
+// which is rolled back by the device unless confirmed within the timeout.
+func commitConfirmedCommand(platform string, timeout time.Duration) string {
+	if platform == "juniper_junos" {
+		// junos accepts the timeout in minutes
+		return fmt.Sprintf("commit confirmed %d", int(math.Max(1, math.Ceil(timeout.Minutes()))))
+	}
+
+	if timeout < iosxrMinConfirmTimeout {
+		timeout = iosxrMinConfirmTimeout
+	}
+
+	return fmt.Sprintf("commit confirmed %d", int(math.Ceil(timeout.Seconds())))
+}
+
+// configPriv returns the configuration privilege level used by the load-config operation
+// with the opts options.
+func configPriv(opts ...cfgutil.Option) string {
+	o, err := cfgutil.NewOperationOptions(opts...)
+	if err == nil {
+		if _, ok := o.Kwargs["exclusive"]; ok {
+			return "configuration-exclusive"
+		}
+	}
+
+	return "configuration"
+}
+
+// runCommitConfirmed commits the loaded candidate with the commit-confirmed timeout,
+// runs the post-checks after the settle time and sends the confirming commit if they pass.
+// When the post-checks fail or the connection is lost, the commit is not confirmed
+// and the device rolls it back once the timeout expires. The failed post-checks are
+// returned as the errConfigRolledBack error along with the response recording them.
+func (app *appCfg) runCommitConfirmed(
+	name string,
+	d *device,
+	c *scrapligocfg.Cfg,
+	op *cfgOperation,
+	opts ...cfgutil.Option,
+) (*cfgresponse.Response, error) {
+	r := cfgresponse.NewResponse(commitConfirmedOp, c.Conn.Transport.GetHost())
+	priv := opoptions.WithPrivilegeLevel(configPriv(opts...))
+
+	cr, err := c.Conn.SendConfig(commitConfirmedCommand(d.Platform, op.CommitConfirmed), priv)
+	if err == nil {
+		err = cr.Failed
+	}
+
+	if err != nil {
+		log.Errorf("commit-config operation failed for device %s; error: %+v\n", name, err)
+
+		return nil, err
+	}
+
+	rs := []*response.Response{cr}
+
+	// the candidate is committed by now, aborting it resets the cfg session
+	if _, err := c.AbortConfig(); err != nil {
+		log.Errorf("abort-config operation failed for device %s; error: %+v\n", name, err)
+
+		return nil, err
+	}
+
+	time.Sleep(op.Settle)
+
+	if failures := app.postCheckFailures(name, d, c); len(failures) != 0 {
+		log.Warnf("post-checks failed for device %s, the commit is not confirmed and will be rolled back in %s",
+			name, op.CommitConfirmed)
+
+		r.Record(rs, fmt.Sprintf("failed post-checks:\n%s\ncommit not confirmed, rolled back by the device in %s",
+			strings.Join(failures, "\n"), op.CommitConfirmed))
+
+		return r, fmt.Errorf("%w by the device in %s, commit not confirmed after failed post-checks: %s",
+			errConfigRolledBack, op.CommitConfirmed, strings.Join(failures, "; "))
+	}
+
+	confirm, err := c.Conn.SendConfig("commit", priv)
+	if err == nil {
+		err = confirm.Failed
+	}
+
+	if err != nil {
+		log.Errorf("failed to confirm the commit for device %s; error: %+v\n", name, err)
+
+		return nil, err
+	}
+
+	r.Record(append(rs, confirm), "commit confirmed")
+
+	return r, nil
+}
@@ -0,0 +1,120 @@
+package commando
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	cfgutil "github.com/scrapli/scrapligocfg/util"
+)
+
+func TestCommitConfirmedCommand(t *testing.T) {
+	tests := []struct {
+		platform string
+		timeout  time.Duration
+		want     string
+	}{
+		{"juniper_junos", 5 * time.Minute, "commit confirmed 5"},
+		{"juniper_junos", 90 * time.Second, "commit confirmed 2"},
+		{"juniper_junos", 10 * time.Second, "commit confirmed 1"},
+		{"cisco_iosxr", 5 * time.Minute, "commit confirmed 300"},
+		{"cisco_iosxr", 45500 * time.Millisecond, "commit confirmed 46"},
+		{"cisco_iosxr", 10 * time.Second, "commit confirmed 30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.platform+" "+tt.timeout.String(), func(t *testing.T) {
+			if got := commitConfirmedCommand(tt.platform, tt.timeout); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCommitConfirmed(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform string
+		op       *cfgOperation
+		wantErr  error
+	}{
+		{
+			name:     "not set",
+			platform: "arista_eos",
+			op:       &cfgOperation{OperationType: loadConfigOp, Commit: true},
+		},
+		{
+			name:     "junos",
+			platform: "juniper_junos",
+			op:       &cfgOperation{OperationType: loadConfigOp, Commit: true, CommitConfirmed: 5 * time.Minute},
+		},
+		{
+			name:     "iosxr with settle",
+			platform: "cisco_iosxr",
+			op: &cfgOperation{
+				OperationType: loadConfigOp, Commit: true, CommitConfirmed: time.Minute, Settle: 30 * time.Second,
+			},
+		},
+		{
+			name:     "without commit",
+			platform: "juniper_junos",
+			op:       &cfgOperation{OperationType: loadConfigOp, CommitConfirmed: 5 * time.Minute},
+			wantErr:  errInvalidCfgOperation,
+		},
+		{
+			name:     "not load-config",
+			platform: "juniper_junos",
+			op:       &cfgOperation{OperationType: validateOp, Commit: true, CommitConfirmed: 5 * time.Minute},
+			wantErr:  errInvalidCfgOperation,
+		},
+		{
+			name:     "unsupported platform",
+			platform: "arista_eos",
+			op:       &cfgOperation{OperationType: loadConfigOp, Commit: true, CommitConfirmed: 5 * time.Minute},
+			wantErr:  errInvalidCfgOperation,
+		},
+		{
+			name:     "with rollback-on-failure",
+			platform: "juniper_junos",
+			op: &cfgOperation{
+				OperationType: loadConfigOp, Commit: true, CommitConfirmed: 5 * time.Minute, RollbackOnFailure: true,
+			},
+			wantErr: errInvalidCfgOperation,
+		},
+		{
+			name:     "settle not shorter than the timeout",
+			platform: "cisco_iosxr",
+			op: &cfgOperation{
+				OperationType: loadConfigOp, Commit: true, CommitConfirmed: time.Minute, Settle: time.Minute,
+			},
+			wantErr: errInvalidCfgOperation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op.validateCommitConfirmed(tt.platform); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigPriv(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []cfgutil.Option
+		want string
+	}{
+		{"default", nil, "configuration"},
+		{"exclusive", []cfgutil.Option{withExclusive()}, "configuration-exclusive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := configPriv(tt.opts...); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package commando
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	cfgresponse "github.com/scrapli/scrapligocfg/response"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+const defaultPager = "less -R"
+
+// confirmCommit shows the candidate diffs of the devices in a pager and asks the operator
+// to approve all of them, approve them per device, or abort.
+// It returns the devices approved to commit.
+func confirmCommit(diffs map[string]*cfgresponse.DiffResponse, failed []string) map[string]bool {
+	devs := sortedKeys(diffs)
+	approved := map[string]bool{}
+
+	if len(devs) == 0 {
+		return approved
+	}
+
+	showInPager(candidateDiffs(diffs, failed))
+
+	in := bufio.NewReader(os.Stdin)
+
+	switch ask(in, "Commit the candidate configs? [a]ll, [p]er device, a[b]ort: ") {
+	case "a", "all":
+		for _, name := range devs {
+			approved[name] = true
+		}
+	case "p", "per device":
+		for _, name := range devs {
+			a := ask(in, fmt.Sprintf("Commit device %s? [y/N]: ", name))
+			approved[name] = a == "y" || a == "yes"
+		}
+	default:
+		log.Warn("commit aborted by the operator")
+	}
+
+	return approved
+}
+
+// candidateDiffs returns the combined diffs of the candidate configs with the device names.
+// The device native diff is used when the platform provides it.
+func candidateDiffs(diffs map[string]*cfgresponse.DiffResponse, failed []string) string {
+	var b strings.Builder
+
+	for _, name := range sortedKeys(diffs) {
+		fmt.Fprintf(&b, "=== %s ===\n", name)
+
+		dr := diffs[name]
+
+		d := dr.DeviceDiff
+
+		switch {
+		case strings.TrimSpace(d) != "":
+		case dr.Current == dr.Candidate:
+			d = "no changes\n"
+		default:
+			d = unifiedDiff("running", "candidate", dr.Current, dr.Candidate)
+		}
+
+		b.WriteString(strings.TrimRight(d, "\n") + "\n\n")
+	}
+
+	if len(failed) != 0 {
+		fmt.Fprintf(&b, "load-config failed on: %s; these devices won't be committed\n",
+			strings.Join(failed, ", "))
+	}
+
+	return b.String()
+}
+
+// showInPager shows the text in the $PAGER (less by default) when stdout is a terminal,
+// otherwise or if the pager fails to start the text is printed to stdout.
+func showInPager(text string) {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = defaultPager
+	}
+
+	if args := strings.Fields(pager); len(args) != 0 && term.IsTerminal(int(os.Stdout.Fd())) {
+		cmd := exec.Command(args[0], args[1:]...) //nolint:gosec
+		cmd.Stdin = strings.NewReader(text)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		if err := cmd.Run(); err == nil {
+			return
+		}
+	}
+
+	fmt.Print(text)
+}
+
+// ask prints the prompt and returns the lowercased answer read from in.
+// An empty string is returned if the answer can't be read.
+func ask(in *bufio.Reader, prompt string) string {
+	fmt.Print(prompt)
+
+	a, err := in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return ""
+	}
+
+	return strings.ToLower(strings.TrimSpace(a))
+}
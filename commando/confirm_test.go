@@ -0,0 +1,90 @@
+package commando
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	cfgresponse "github.com/scrapli/scrapligocfg/response"
+)
+
+// discardStdout discards the writes to os.Stdout for the duration of the test.
+func discardStdout(t *testing.T) {
+	t.Helper()
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = devNull
+
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
+
+func testDiffs() map[string]*cfgresponse.DiffResponse {
+	diffs := map[string]*cfgresponse.DiffResponse{}
+
+	for name, cfg := range map[string][2]string{
+		"r1": {"hostname r1\n", "hostname r1\n"},
+		"r2": {"hostname r2\n", "hostname r2-new\n"},
+		"r3": {"hostname r3\n", "hostname r3-new\n"},
+	} {
+		dr := cfgresponse.NewDiffResponse(name)
+		dr.Current, dr.Candidate = cfg[0], cfg[1]
+		diffs[name] = dr
+	}
+
+	diffs["r3"].DeviceDiff = "[edit system]\n-  host-name r3;\n+  host-name r3-new;\n"
+
+	return diffs
+}
+
+func TestCandidateDiffs(t *testing.T) {
+	got := candidateDiffs(testDiffs(), []string{"r4"})
+
+	want := "=== r1 ===\nno changes\n\n" +
+		"=== r2 ===\n" + unifiedDiff("running", "candidate", "hostname r2\n", "hostname r2-new\n") + "\n" +
+		"=== r3 ===\n[edit system]\n-  host-name r3;\n+  host-name r3-new;\n\n" +
+		"load-config failed on: r4; these devices won't be committed\n"
+
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if !strings.Contains(got, "-hostname r2\n+hostname r2-new\n") {
+		t.Fatalf("the fallback diff has no changed lines:\n%s", got)
+	}
+}
+
+func TestConfirmCommit(t *testing.T) {
+	discardStdout(t)
+	t.Setenv("PAGER", "cat")
+
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]bool
+	}{
+		{"all", "a\n", map[string]bool{"r1": true, "r2": true, "r3": true}},
+		{"per device", "p\ny\nn\nYES\n", map[string]bool{"r1": true, "r2": false, "r3": true}},
+		{"per device without the answers", "p\ny\n", map[string]bool{"r1": true, "r2": false, "r3": false}},
+		{"abort", "b\n", map[string]bool{}},
+		{"no answer", "", map[string]bool{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withStdin(t, tt.input)
+
+			if got := confirmCommit(testDiffs(), nil); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
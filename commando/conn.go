@@ -1,6 +1,10 @@
 package commando
 
 import (
+	"errors"
+	"strings"
+	"sync"
+
 	"github.com/scrapli/scrapligo/driver/network"
 	"github.com/scrapli/scrapligo/driver/options"
 	"github.com/scrapli/scrapligo/platform"
@@ -9,17 +13,91 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// transportTypes are the transport types the connections can use.
+var transportTypes = []string{ //nolint:gochecknoglobals
+	transport.StandardTransport, transport.SystemTransport, transport.TelnetTransport,
+}
+
 func (app *appCfg) validTransport(t string) bool {
-	switch t {
-	case transport.SystemTransport:
-		return true
-	case transport.StandardTransport:
-		return true
-	case transport.TelnetTransport:
-		return true
-	default:
-		return false
+	return contains(transportTypes, t)
+}
+
+// credentialChain is the list of the credentials names tried in order on authentication failures.
+// In the inventory it can be set as a single name or as a list of names.
+type credentialChain []string
+
+func (c *credentialChain) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*c = credentialChain{name}
+
+		return nil
+	}
+
+	var names []string
+	if err := unmarshal(&names); err != nil {
+		return err
 	}
+
+	*c = names
+
+	return nil
+}
+
+// names returns the credentials names of the chain, or the default credentials if the chain is empty.
+func (c credentialChain) names() []string {
+	if len(c) == 0 {
+		return []string{defaultName}
+	}
+
+	return c
+}
+
+// usedCredentials records the credentials the devices were connected with.
+type usedCredentials struct {
+	mu       sync.Mutex
+	names    map[string]string
+	fallback map[string]bool
+}
+
+func newUsedCredentials() *usedCredentials {
+	return &usedCredentials{
+		names:    map[string]string{},
+		fallback: map[string]bool{},
+	}
+}
+
+func (u *usedCredentials) record(dev, creds string, fallback bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.names[dev] = creds
+	u.fallback[dev] = fallback
+}
+
+// get returns the credentials the device was connected with and whether they are a fallback,
+// i.e. not the first credentials of the device's chain.
+func (u *usedCredentials) get(dev string) (string, bool) {
+	if u == nil {
+		return "", false
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.names[dev], u.fallback[dev]
+}
+
+// sshAuthError is the prefix of the error golang.org/x/crypto/ssh returns from ssh.Dial
+// when the server rejects all the authentication methods.
+// The crypto/ssh error isn't wrapped, so it can only be matched by its message.
+const sshAuthError = "ssh: handshake failed: ssh: unable to authenticate"
+
+// isAuthError returns true if the connection failed due to the rejected credentials.
+// The system and telnet transports return the scrapligo util.ErrAuthError,
+// the standard transport returns the crypto/ssh error as is.
+func isAuthError(err error) bool {
+	return errors.Is(err, util.ErrAuthError) || strings.HasPrefix(err.Error(), sshAuthError)
 }
 
 func (app *appCfg) loadCredentials(o []util.Option, c string) ([]util.Option, error) {
@@ -87,18 +165,12 @@ func (app *appCfg) loadTransport(o []util.Option, t string) ([]util.Option, erro
 	return o, nil
 }
 
-// loadOptions loads options from the provided inventory.
-func (app *appCfg) loadOptions(d *device) ([]util.Option, error) {
+// loadOptions loads options from the provided inventory using the c credentials.
+func (app *appCfg) loadOptions(d *device, c string) ([]util.Option, error) {
 	var o []util.Option
 
 	var err error
 
-	c := defaultName
-
-	if d.Credentials != "" {
-		c = d.Credentials
-	}
-
 	o, err = app.loadCredentials(o, c)
 	if err != nil {
 		return o, err
@@ -118,10 +190,37 @@ func (app *appCfg) loadOptions(d *device) ([]util.Option, error) {
 	return o, err
 }
 
+// openCoreConn opens the connection to the device trying the credentials of its chain in order.
+// The next credentials are tried on the authentication failures only.
 func (app *appCfg) openCoreConn(name string, d *device) (*network.Driver, error) {
+	chain := d.Credentials.names()
+
+	for idx, c := range chain {
+		driver, err := app.openCoreConnWith(name, d, c)
+		if err == nil {
+			if idx != 0 {
+				log.Warnf("device %s authenticated with the fallback credentials %s", name, c)
+			}
+
+			app.usedCreds.record(name, c, idx != 0)
+
+			return driver, nil
+		}
+
+		if idx == len(chain)-1 || !isAuthError(err) {
+			return nil, err
+		}
+
+		log.Warnf("authentication with credentials %s failed for device %s, trying %s", c, name, chain[idx+1])
+	}
+
+	return nil, errInvalidCredentialsName
+}
+
+func (app *appCfg) openCoreConnWith(name string, d *device, c string) (*network.Driver, error) {
 	var driver *network.Driver
 
-	o, err := app.loadOptions(d)
+	o, err := app.loadOptions(d, c)
 	if err != nil {
 		log.Errorf(
 			"failed to load credentials or transport options for %s; error: %+v\n",
@@ -138,19 +237,19 @@ func (app *appCfg) openCoreConn(name string, d *device) (*network.Driver, error)
 		o...,
 	)
 	if err != nil {
-		log.Errorf("failed to create platform instance for device %s; error: %+v\n", err, name)
+		log.Errorf("failed to create platform instance for device %s; error: %+v\n", name, err)
 		return nil, err
 	}
 
 	driver, err = plat.GetNetworkDriver()
 	if err != nil {
-		log.Errorf("failed to create driver instance for device %s; error: %+v\n", err, name)
+		log.Errorf("failed to create driver instance for device %s; error: %+v\n", name, err)
 		return nil, err
 	}
 
 	err = driver.Open()
 	if err != nil {
-		log.Errorf("failed to open connection to device %s; error: %+v\n", err, name)
+		log.Errorf("failed to open connection to device %s with credentials %s; error: %+v\n", name, c, err)
 
 		return nil, err
 	}
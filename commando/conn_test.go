@@ -0,0 +1,93 @@
+package commando
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/scrapli/scrapligo/util"
+	"golang.org/x/crypto/ssh"
+)
+
+// rejectedSSHAuth returns the error crypto/ssh returns to the client
+// when the server rejects its credentials.
+func rejectedSSHAuth(t *testing.T) error {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srvCfg := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, errors.New("rejected")
+		},
+	}
+	srvCfg.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		ssh.NewServerConn(c, srvCfg) //nolint:errcheck
+	}()
+
+	_, err = ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+		User:            "admin",
+		Auth:            []ssh.AuthMethod{ssh.Password("wrong")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //nolint:gosec
+	})
+	if err == nil {
+		t.Fatal("ssh server accepted the wrong password")
+	}
+
+	return err
+}
+
+func TestIsAuthError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"crypto/ssh handshake", rejectedSSHAuth(t), true},
+		{
+			"crypto/ssh rejected credentials",
+			errors.New("ssh: handshake failed: ssh: unable to authenticate, " +
+				"attempted methods [none password], no supported methods remain"),
+			true,
+		},
+		{"scrapligo auth error", fmt.Errorf("%w: password prompt seen more than once", util.ErrAuthError), true},
+		{"connection refused", errors.New("dial tcp 192.0.2.1:22: connect: connection refused"), false},
+		{"handshake failure", errors.New("ssh: handshake failed: EOF"), false},
+		{"unrelated message", errors.New("command failed: unable to authenticate user on radius"), false},
+		{"io error", io.ErrUnexpectedEOF, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isAuthError(tt.err); got != tt.want {
+				t.Fatalf("isAuthError(%q) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,664 @@
+package commando
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	diffContextLines = 3
+	// edits after which the diff search settles for a split that may not be minimal.
+	diffMaxCost     = 1024
+	outputDirPrefix = "outputs"
+)
+
+// outputSet holds the outputs of a run keyed by the device name
+// and then by the output file name.
+type outputSet map[string]map[string]string
+
+// loadOutputDir loads the outputs saved by the file writer in the dir directory.
+func loadOutputDir(dir string) (outputSet, error) {
+	devs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	outs := outputSet{}
+
+	for _, d := range devs {
+		if !d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+
+		files, err := os.ReadDir(path.Join(dir, d.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		outs[d.Name()] = map[string]string{}
+
+		for _, f := range files {
+			if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+				continue
+			}
+
+			b, err := os.ReadFile(path.Join(dir, d.Name(), f.Name()))
+			if err != nil {
+				return nil, err
+			}
+
+			outs[d.Name()][f.Name()] = string(b)
+		}
+	}
+
+	return outs, nil
+}
+
+// previousOutputs returns the name and the outputs of the previous run: the HEAD commit
+// of the git repository with the git output, or the previous outputs directory otherwise.
+// An empty name is returned if there is no previous run.
+func (app *appCfg) previousOutputs() (string, outputSet, error) {
+	if app.output == gitOutput {
+		return loadGitOutputs(app.gitRepo)
+	}
+
+	dir := app.previousOutputDir()
+	if dir == "" {
+		return "", nil, nil
+	}
+
+	outs, err := loadOutputDir(dir)
+
+	return dir, outs, err
+}
+
+// previousOutputDir returns the outputs directory of the previous run in the current working directory:
+// the outputs directory without the timestamp if the run doesn't add it and the directory exists,
+// or the directory with the latest timestamp in its name. An empty string is returned if there is none.
+func (app *appCfg) previousOutputDir() string {
+	if fi, err := os.Stat(outputDirPrefix); !app.timestamp && err == nil && fi.IsDir() {
+		return outputDirPrefix
+	}
+
+	dirs, _ := filepath.Glob(outputDirPrefix + "_*")
+
+	var (
+		prev     string
+		prevTime time.Time
+	)
+
+	for _, d := range dirs {
+		t, err := time.Parse(time.RFC3339, strings.TrimPrefix(d, outputDirPrefix+"_"))
+		if err != nil {
+			continue
+		}
+
+		if fi, err := os.Stat(d); err != nil || !fi.IsDir() {
+			continue
+		}
+
+		if prev == "" || t.After(prevTime) {
+			prev, prevTime = d, t
+		}
+	}
+
+	if prev == "" {
+		if fi, err := os.Stat(outputDirPrefix); err == nil && fi.IsDir() {
+			return outputDirPrefix
+		}
+	}
+
+	return prev
+}
+
+// loadGitOutputs loads the outputs committed to the HEAD of the repo git repository.
+// An empty name is returned if the repository doesn't exist or has no commits.
+func loadGitOutputs(repo string) (string, outputSet, error) {
+	if _, err := os.Stat(path.Join(repo, ".git")); errors.Is(err, os.ErrNotExist) {
+		return "", nil, nil
+	}
+
+	w := &gitWriter{repo: repo}
+
+	if _, err := w.git("rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		return "", nil, nil //nolint:nilerr
+	}
+
+	archive, err := w.git("archive", "--format=tar", "HEAD")
+	if err != nil {
+		return "", nil, err
+	}
+
+	outs := outputSet{}
+
+	tr := tar.NewReader(bytes.NewReader([]byte(archive)))
+
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		// the same layout as loadOutputDir reads: the output files of the device directories
+		dev, file, ok := strings.Cut(h.Name, "/")
+		if h.Typeflag != tar.TypeReg || !ok || strings.Contains(file, "/") ||
+			strings.HasPrefix(dev, ".") || strings.HasPrefix(file, ".") {
+			continue
+		}
+
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if outs[dev] == nil {
+			outs[dev] = map[string]string{}
+		}
+
+		outs[dev][file] = string(b)
+	}
+
+	return repo + "@HEAD", outs, nil
+}
+
+// outputDiff is a diff of a single output file of a device.
+type outputDiff struct {
+	device  string
+	file    string
+	unified string
+}
+
+// diffReport is the result of comparing the outputs of two runs.
+type diffReport struct {
+	nameA, nameB string
+	changed      map[string][]string // device name -> changed output files
+	onlyA        []string            // devices present only in run A
+	onlyB        []string            // devices present only in run B
+	unchanged    []string            // devices with identical outputs
+	diffs        []outputDiff
+}
+
+// diffOutputs compares the outputs of two runs.
+// When devices is not empty, only the listed devices are compared.
+func diffOutputs(nameA, nameB string, a, b outputSet, devices ...string) *diffReport {
+	r := &diffReport{
+		nameA:   nameA,
+		nameB:   nameB,
+		changed: map[string][]string{},
+	}
+
+	names := devices
+	if len(names) == 0 {
+		names = unionKeys(a, b)
+	}
+
+	sort.Strings(names)
+
+	for _, dev := range names {
+		outA, okA := a[dev]
+		outB, okB := b[dev]
+
+		switch {
+		case !okA:
+			r.onlyB = append(r.onlyB, dev)
+
+			continue
+		case !okB:
+			r.onlyA = append(r.onlyA, dev)
+
+			continue
+		}
+
+		for _, f := range unionKeys(outA, outB) {
+			if outA[f] == outB[f] {
+				continue
+			}
+
+			r.changed[dev] = append(r.changed[dev], f)
+			r.diffs = append(r.diffs, outputDiff{
+				device: dev,
+				file:   f,
+				unified: unifiedDiff(
+					path.Join(nameA, dev, f), path.Join(nameB, dev, f),
+					outA[f], outB[f],
+				),
+			})
+		}
+
+		if _, ok := r.changed[dev]; !ok {
+			r.unchanged = append(r.unchanged, dev)
+		}
+	}
+
+	return r
+}
+
+// write writes the summary of the changes followed by the unified diffs.
+func (r *diffReport) write(w io.Writer) {
+	fmt.Fprintf(w, "comparing %s with %s: %d changed, %d unchanged, %d only in %s, %d only in %s\n",
+		r.nameA, r.nameB, len(r.changed), len(r.unchanged),
+		len(r.onlyA), r.nameA, len(r.onlyB), r.nameB)
+
+	for _, dev := range sortedKeys(r.changed) {
+		fmt.Fprintf(w, "  %s: %s\n", dev, strings.Join(r.changed[dev], ", "))
+	}
+
+	for _, dev := range r.onlyA {
+		fmt.Fprintf(w, "  %s: only in %s\n", dev, r.nameA)
+	}
+
+	for _, dev := range r.onlyB {
+		fmt.Fprintf(w, "  %s: only in %s\n", dev, r.nameB)
+	}
+
+	for _, d := range r.diffs {
+		fmt.Fprintf(w, "\n%s", d.unified)
+	}
+}
+
+// unifiedDiff returns the unified diff of a and b strings.
+// The lines of a side not terminated with a newline are marked with the "\\ No newline at end of file" line.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	type line struct {
+		op   byte
+		text string
+		eol  bool // the line is terminated with a newline
+		posA int  // line number in a, 1-based
+		posB int  // line number in b, 1-based
+	}
+
+	var (
+		lines      []line
+		posA, posB int
+	)
+
+	for _, l := range diffLines(splitLines(a), splitLines(b)) {
+		switch l.op {
+		case ' ':
+			posA++
+			posB++
+		case '-':
+			posA++
+		case '+':
+			posB++
+		}
+
+		text := strings.TrimSuffix(l.text, "\n")
+
+		lines = append(lines, line{op: l.op, text: text, eol: text != l.text, posA: posA, posB: posB})
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "--- %s\n+++ %s\n", nameA, nameB)
+
+	for i := 0; i < len(lines); i++ {
+		if lines[i].op == ' ' {
+			continue
+		}
+
+		// extend the hunk while the changes are within the context distance
+		start := max(0, i-diffContextLines)
+		end := i
+
+		for j := i; j < len(lines) && j <= end+2*diffContextLines; j++ {
+			if lines[j].op != ' ' {
+				end = j
+			}
+		}
+
+		end = min(len(lines)-1, end+diffContextLines)
+
+		var startA, startB, lenA, lenB int
+
+		for _, l := range lines[start : end+1] {
+			if l.op != '+' {
+				if lenA == 0 {
+					startA = l.posA
+				}
+				lenA++
+			}
+
+			if l.op != '-' {
+				if lenB == 0 {
+					startB = l.posB
+				}
+				lenB++
+			}
+		}
+
+		// the empty sides of the hunk point to the line preceding it, or 0 at the start of the file
+		if lenA == 0 {
+			startA = lines[start].posA
+		}
+
+		if lenB == 0 {
+			startB = lines[start].posB
+		}
+
+		fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", startA, lenA, startB, lenB)
+
+		for _, l := range lines[start : end+1] {
+			fmt.Fprintf(sb, "%c%s\n", l.op, l.text)
+
+			if !l.eol {
+				sb.WriteString("\\ No newline at end of file\n")
+			}
+		}
+
+		i = end
+	}
+
+	return sb.String()
+}
+
+// splitLines splits s into the lines keeping their newlines.
+// The last line has no newline if s isn't newline terminated.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+
+	// s ending with a newline or empty leaves an empty element at the end
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// diffOp is a single line of a diff with the op being one of ' ', '-' or '+'.
+type diffOp struct {
+	op   byte
+	text string
+}
+
+// diffLines returns the line by line diff of a and b
+// computed with the linear space variant of the Myers O(ND) algorithm.
+// The lines present only in a or only in b can't be common, so they are left out of the search,
+// which makes the diffs of mostly different outputs cheap.
+// The removed lines of a changed block are listed before the added ones.
+func diffLines(a, b []string) []diffOp {
+	ids := map[string]int{}
+
+	// lineIDs returns the ids of the lines, the equal lines have the same id
+	lineIDs := func(lines []string) []int {
+		l := make([]int, len(lines))
+
+		for i, s := range lines {
+			id, ok := ids[s]
+			if !ok {
+				id = len(ids)
+				ids[s] = id
+			}
+
+			l[i] = id
+		}
+
+		return l
+	}
+
+	idsA, idsB := lineIDs(a), lineIDs(b)
+
+	// common returns the indexes and the ids of the lines which ids are in other
+	common := func(lines, other []int) ([]int, []int) {
+		in := make(map[int]struct{}, len(other))
+		for _, id := range other {
+			in[id] = struct{}{}
+		}
+
+		var idx, kept []int
+
+		for i, id := range lines {
+			if _, ok := in[id]; ok {
+				idx = append(idx, i)
+				kept = append(kept, id)
+			}
+		}
+
+		return idx, kept
+	}
+
+	idxA, keptA := common(idsA, idsB)
+	idxB, keptB := common(idsB, idsA)
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+
+	// i and j are the next lines of a and b, the lines before the common ones are the changes
+	var i, j, ka, kb int
+
+	for _, op := range (&myers{a: keptA, b: keptB}).diff(nil, 0, len(keptA), 0, len(keptB)) {
+		switch op {
+		case '-':
+			ka++
+		case '+':
+			kb++
+		case ' ':
+			for ; i < idxA[ka]; i++ {
+				ops = append(ops, diffOp{'-', a[i]})
+			}
+
+			for ; j < idxB[kb]; j++ {
+				ops = append(ops, diffOp{'+', b[j]})
+			}
+
+			ops = append(ops, diffOp{' ', a[i]})
+			i, j, ka, kb = i+1, j+1, ka+1, kb+1
+		}
+	}
+
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+
+	return ops
+}
+
+// myers computes the shortest edit script of the a and b line ids.
+type myers struct {
+	a, b   []int
+	vf, vb []int // furthest reaching x of the forward and backward searches by diagonal
+}
+
+// diff appends the ops turning a[a0:a1] into b[b0:b1] to ops. The common prefix and suffix
+// are trimmed and the remaining lines are split at the middle snake of their shortest edit script,
+// the parts before and after the snake are diffed recursively.
+func (m *myers) diff(ops []byte, a0, a1, b0, b1 int) []byte {
+	for a0 < a1 && b0 < b1 && m.a[a0] == m.b[b0] {
+		ops = append(ops, ' ')
+		a0++
+		b0++
+	}
+
+	suf := 0
+	for a0 < a1 && b0 < b1 && m.a[a1-1] == m.b[b1-1] {
+		a1--
+		b1--
+		suf++
+	}
+
+	switch {
+	case a0 == a1:
+		for ; b0 < b1; b0++ {
+			ops = append(ops, '+')
+		}
+	case b0 == b1:
+		for ; a0 < a1; a0++ {
+			ops = append(ops, '-')
+		}
+	default:
+		x, y, u, v := m.middleSnake(a0, a1, b0, b1)
+
+		ops = m.diff(ops, a0, x, b0, y)
+
+		for ; x < u; x++ {
+			ops = append(ops, ' ')
+		}
+
+		ops = m.diff(ops, u, a1, v, b1)
+	}
+
+	for ; suf > 0; suf-- {
+		ops = append(ops, ' ')
+	}
+
+	return ops
+}
+
+// middleSnake returns the start (x, y) and the end (u, v) of the middle snake of the shortest
+// edit script of a[a0:a1] and b[b0:b1], found by running the search from both ends
+// until the paths overlap. Once the search exceeds diffMaxCost edits, the lines are split
+// at the furthest reaching point of the searches instead, trading the minimality of the diff for time.
+// The ranges must not be empty and must differ in their first and last lines,
+// so that both parts around the snake are smaller than the ranges.
+func (m *myers) middleSnake(a0, a1, b0, b1 int) (x, y, u, v int) {
+	n, mm := a1-a0, b1-b0
+	delta := n - mm
+	odd := delta%2 != 0
+	maxD := (n + mm + 1) / 2
+
+	// diagonals k = x - y are offset by off, backward x counts the lines consumed from the ends
+	off := maxD + 1
+	if len(m.vf) < 2*off+1 {
+		m.vf, m.vb = make([]int, 2*off+1), make([]int, 2*off+1)
+	}
+
+	m.vf[off+1], m.vb[off+1] = 0, 0
+
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && m.vf[off+k-1] < m.vf[off+k+1]) {
+				x = m.vf[off+k+1]
+			} else {
+				x = m.vf[off+k-1] + 1
+			}
+
+			y = x - k
+			u, v = x, y
+
+			for u < n && v < mm && m.a[a0+u] == m.b[b0+v] {
+				u++
+				v++
+			}
+
+			m.vf[off+k] = u
+
+			// the backward diagonal of the forward diagonal k is delta-k
+			if kb := delta - k; odd && kb >= -(d-1) && kb <= d-1 && u+m.vb[off+kb] >= n {
+				return a0 + x, b0 + y, a0 + u, b0 + v
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && m.vb[off+k-1] < m.vb[off+k+1]) {
+				x = m.vb[off+k+1]
+			} else {
+				x = m.vb[off+k-1] + 1
+			}
+
+			y = x - k
+			u, v = x, y
+
+			for u < n && v < mm && m.a[a1-1-u] == m.b[b1-1-v] {
+				u++
+				v++
+			}
+
+			m.vb[off+k] = u
+
+			if kf := delta - k; !odd && kf >= -d && kf <= d && u+m.vf[off+kf] >= n {
+				return a1 - u, b1 - v, a1 - x, b1 - y
+			}
+		}
+
+		if d >= diffMaxCost {
+			x, y = m.furthestPoint(d, off, n, mm)
+
+			return a0 + x, b0 + y, a0 + x, b0 + y
+		}
+	}
+
+	// unreachable, the paths overlap by maxD at the latest
+	return a0, b0, a1, b1
+}
+
+// furthestPoint returns the point reached by the forward or the backward search after d edits,
+// which is the furthest from its start and lies strictly inside the n by mm edit graph.
+func (m *myers) furthestPoint(d, off, n, mm int) (int, int) {
+	bestX, bestY, best := 0, 0, -1
+
+	for k := -d; k <= d; k += 2 {
+		if x, y := m.vf[off+k], m.vf[off+k]-k; x <= n && y >= 0 && y <= mm && x+y < n+mm && x+y > best {
+			bestX, bestY, best = x, y, x+y
+		}
+
+		if x, y := m.vb[off+k], m.vb[off+k]-k; x <= n && y >= 0 && y <= mm && x+y < n+mm && x+y > best {
+			bestX, bestY, best = n-x, mm-y, x+y
+		}
+	}
+
+	return bestX, bestY
+}
+
+// unionKeys returns the sorted union of the keys of the a and b maps.
+func unionKeys[T any](a, b map[string]T) []string {
+	keys := map[string]struct{}{}
+
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+
+	return sortedKeys(keys)
+}
+
+// sortedKeys returns the sorted keys of the m map.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// runDiff prints the diff between the outputs saved in the dirA and dirB directories.
+// The normalisation rules from the inventory, if it exists, are applied to both runs.
+func (app *appCfg) runDiff(dirA, dirB string) error {
+	if err := app.loadNormalizer(); err != nil {
+		return err
+	}
+
+	a, err := loadOutputDir(dirA)
+	if err != nil {
+		return err
+	}
+
+	b, err := loadOutputDir(dirB)
+	if err != nil {
+		return err
+	}
+
+	diffOutputs(dirA, dirB, app.normalizer.applySet(a), app.normalizer.applySet(b)).write(os.Stdout)
+
+	return nil
+}
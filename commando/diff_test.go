@@ -0,0 +1,308 @@
+package commando
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// opLines returns the diff ops as the op prefixed lines, e.g. "-a".
+func opLines(ops []diffOp) []string {
+	lines := make([]string, 0, len(ops))
+
+	for _, o := range ops {
+		lines = append(lines, string(o.op)+o.text)
+	}
+
+	return lines
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{name: "equal", a: "a b c", b: "a b c", want: []string{" a", " b", " c"}},
+		{name: "both empty", a: "", b: "", want: []string{}},
+		{name: "all added", a: "", b: "a b", want: []string{"+a", "+b"}},
+		{name: "all removed", a: "a b", b: "", want: []string{"-a", "-b"}},
+		{name: "changed middle", a: "a b c", b: "a x c", want: []string{" a", "-b", "+x", " c"}},
+		{name: "inserted", a: "a c", b: "a b c", want: []string{" a", "+b", " c"}},
+		{name: "removed", a: "a b c", b: "a c", want: []string{" a", "-b", " c"}},
+		{
+			name: "moved line",
+			a:    "a b c d",
+			b:    "b c d a",
+			want: []string{"-a", " b", " c", " d", "+a"},
+		},
+		{
+			name: "interleaved",
+			a:    "a b c d e",
+			b:    "a x c y e",
+			want: []string{" a", "-b", "+x", " c", "-d", "+y", " e"},
+		},
+		{
+			name: "repeated lines",
+			a:    "! a ! b !",
+			b:    "! b ! a !",
+			want: []string{" !", "-a", "-!", " b", "+!", "+a", " !"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := opLines(diffLines(strings.Fields(tt.a), strings.Fields(tt.b)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDiffLinesLarge checks the large outputs differing in a few lines
+// are diffed line by line.
+func TestDiffLinesLarge(t *testing.T) {
+	var a, b []string
+
+	for i := 0; i < 20000; i++ {
+		l := fmt.Sprintf("interface Ethernet%d", i)
+
+		a = append(a, l)
+
+		switch i {
+		case 100, 15000:
+			b = append(b, l+" changed")
+		case 9000:
+		default:
+			b = append(b, l)
+		}
+	}
+
+	var changed []string
+
+	for _, o := range diffLines(a, b) {
+		if o.op != ' ' {
+			changed = append(changed, string(o.op)+o.text)
+		}
+	}
+
+	want := []string{
+		"-interface Ethernet100", "+interface Ethernet100 changed",
+		"-interface Ethernet9000",
+		"-interface Ethernet15000", "+interface Ethernet15000 changed",
+	}
+
+	if !reflect.DeepEqual(changed, want) {
+		t.Fatalf("got %q, want %q", changed, want)
+	}
+}
+
+// TestDiffLinesCostLimit checks the diff of the outputs too different for the minimal search
+// still reproduces them.
+func TestDiffLinesCostLimit(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	a := make([]string, 5000)
+	for i := range a {
+		a[i] = fmt.Sprint(i % 1000)
+	}
+
+	b := append([]string(nil), a...)
+	rnd.Shuffle(len(b), func(i, j int) { b[i], b[j] = b[j], b[i] })
+
+	var gotA, gotB []string
+
+	for _, o := range diffLines(a, b) {
+		if o.op != '+' {
+			gotA = append(gotA, o.text)
+		}
+
+		if o.op != '-' {
+			gotB = append(gotB, o.text)
+		}
+	}
+
+	if !reflect.DeepEqual(gotA, a) || !reflect.DeepEqual(gotB, b) {
+		t.Fatal("the diff doesn't reproduce the inputs")
+	}
+}
+
+// TestDiffLinesMinimal checks the diffs of the random inputs reproduce them
+// with the minimal number of the removed and added lines.
+func TestDiffLinesMinimal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	randLines := func() []string {
+		l := make([]string, rnd.Intn(30))
+		for i := range l {
+			l[i] = string(rune('a' + rnd.Intn(4)))
+		}
+
+		return l
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randLines(), randLines()
+
+		gotA, gotB, edits := []string{}, []string{}, 0
+
+		for _, o := range diffLines(a, b) {
+			if o.op != '+' {
+				gotA = append(gotA, o.text)
+			}
+
+			if o.op != '-' {
+				gotB = append(gotB, o.text)
+			}
+
+			if o.op != ' ' {
+				edits++
+			}
+		}
+
+		if !reflect.DeepEqual(gotA, a) || !reflect.DeepEqual(gotB, b) {
+			t.Fatalf("diff of %q and %q doesn't reproduce them", a, b)
+		}
+
+		if want := len(a) + len(b) - 2*lcsLen(a, b); edits != want {
+			t.Fatalf("diff of %q and %q has %d edits, want %d", a, b, edits, want)
+		}
+	}
+}
+
+// lcsLen returns the length of the longest common subsequence of a and b.
+func lcsLen(a, b []string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "no changes",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "--- A\n+++ B\n",
+		},
+		{
+			name: "change with context",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- A\n+++ B\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			want: "--- A\n+++ B\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		{
+			name: "insertion into empty",
+			a:    "",
+			b:    "a\nb\n",
+			want: "--- A\n+++ B\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "removal of all lines",
+			a:    "a\n",
+			b:    "",
+			want: "--- A\n+++ B\n@@ -1,1 +0,0 @@\n-a\n",
+		},
+		{
+			name: "added newline at end of file",
+			a:    "a\nb",
+			b:    "a\nb\n",
+			want: "--- A\n+++ B\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "change without newline at end of file",
+			a:    "a\nb",
+			b:    "a\nc",
+			want: "--- A\n+++ B\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n" +
+				"+c\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("A", "B", tt.a, tt.b); got != tt.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestUnifiedDiffApplies checks git apply turns a into b with the diff.
+func TestUnifiedDiffApplies(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tests := []struct{ a, b string }{
+		{"", "a\nb\n"},
+		{"a\nb\n", ""},
+		{"a\nb", "a\nb\n"},
+		{"a\nb\n", "a\nc"},
+		{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n", "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n"},
+		{"x\ny\nz\n", "y\nz\nx\n"},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			dir := t.TempDir()
+			f := filepath.Join(dir, "cfg")
+
+			if err := os.WriteFile(f, []byte(tt.a), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			patch := filepath.Join(dir, "cfg.diff")
+			if err := os.WriteFile(patch, []byte(unifiedDiff("a/cfg", "b/cfg", tt.a, tt.b)), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := exec.Command("git", "apply", "cfg.diff")
+			cmd.Dir = dir
+
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git apply failed: %v: %s", err, out)
+			}
+
+			b, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != tt.b {
+				t.Fatalf("got %q, want %q", b, tt.b)
+			}
+		})
+	}
+}
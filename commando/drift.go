@@ -0,0 +1,240 @@
+package commando
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/scrapli/scrapligocfg"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	driftCheckName = "intended-config"
+	driftFileName  = "drift.diff"
+)
+
+// runDrift compares the running config of the devices with their intended configs
+// and reports the drift. It never pushes any config to the devices.
+func (app *appCfg) runDrift() error {
+	i := &inventory{}
+	if err := app.loadInventoryFromYAML(i); err != nil {
+		return err
+	}
+
+	for n, d := range i.Devices {
+		if d.IntendedConfig == "" {
+			delete(i.Devices, n)
+		}
+	}
+
+	if len(i.Devices) == 0 {
+		return errNoIntendedConfigs
+	}
+
+	if err := app.resolveCredentials(i.Credentials, i.Devices); err != nil {
+		return err
+	}
+
+	if app.output == fileOutput {
+		app.outDir = app.fileOutputDir()
+	}
+
+	results := make([]*checkResult, 0, len(i.Devices))
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	wg.Add(len(i.Devices))
+
+	for n, d := range i.Devices {
+		go func(n string, d *device) {
+			defer wg.Done()
+
+			res := app.checkDrift(n, d)
+
+			mu.Lock()
+			results = append(results, res)
+			mu.Unlock()
+		}(n, d)
+	}
+
+	wg.Wait()
+
+	sort.Slice(results, func(a, b int) bool { return results[a].Device < results[b].Device })
+
+	if app.outDir != "" {
+		if err := saveDriftDiffs(app.outDir, results); err != nil {
+			return err
+		}
+	}
+
+	return app.writeReports(&checkReport{Name: "drift", Results: results})
+}
+
+// checkDrift fetches the running config of the device and compares it with the intended config.
+func (app *appCfg) checkDrift(name string, d *device) *checkResult {
+	res := &checkResult{Device: name, Check: driftCheckName}
+
+	intended, err := intendedConfig(name, d)
+	if err != nil {
+		res.Error = err.Error()
+
+		return res
+	}
+
+	driver, err := app.openCoreConn(name, d)
+	if err != nil {
+		res.Error = err.Error()
+
+		return res
+	}
+	defer driver.Close()
+
+	c, err := newCfgSession(name, d, driver)
+	if err != nil {
+		res.Error = err.Error()
+
+		return res
+	}
+
+	r, err := runCfgGetConfig(name, c, &cfgOperation{Source: app.driftSource})
+	if err != nil {
+		res.Error = err.Error()
+
+		return res
+	}
+
+	intended = app.normalizer.apply(name, scrapligocfg.GetConfig, c.Impl.NormalizeConfig(intended))
+	running := app.normalizer.apply(name, scrapligocfg.GetConfig, c.Impl.NormalizeConfig(r.Result))
+
+	res.Details = configDrift(intended, running, app.sectionAware)
+	res.Passed = len(res.Details) == 0
+
+	return res
+}
+
+// intendedConfig reads and renders the intended config template of the device.
+func intendedConfig(name string, d *device) (string, error) {
+	b, err := os.ReadFile(d.IntendedConfig)
+	if err != nil {
+		return "", err
+	}
+
+	return renderTemplate(d.IntendedConfig, string(b), newTemplateData(name, d))
+}
+
+// configDrift returns the differences between the intended and the running configs.
+// Lines prefixed with "-" are missing from the running config,
+// lines prefixed with "+" are present in the running config only.
+func configDrift(intended, running string, sectionAware bool) []string {
+	if sectionAware {
+		return sectionDrift(configSections(intended), configSections(running))
+	}
+
+	// the trailing newlines of the configs are not a drift
+	intended, running = strings.TrimRight(intended, "\n")+"\n", strings.TrimRight(running, "\n")+"\n"
+
+	if intended == running {
+		return nil
+	}
+
+	d := strings.TrimRight(unifiedDiff("intended", "running", intended, running), "\n")
+
+	return strings.Split(d, "\n")
+}
+
+// sectionDrift compares the flattened configs regardless of the order of the lines.
+func sectionDrift(intended, running []string) []string {
+	inRunning := map[string]struct{}{}
+	for _, l := range running {
+		inRunning[l] = struct{}{}
+	}
+
+	inIntended := map[string]struct{}{}
+	for _, l := range intended {
+		inIntended[l] = struct{}{}
+	}
+
+	var drift []string
+
+	for _, l := range intended {
+		if _, ok := inRunning[l]; !ok {
+			drift = append(drift, "- "+l)
+		}
+	}
+
+	for _, l := range running {
+		if _, ok := inIntended[l]; !ok {
+			drift = append(drift, "+ "+l)
+		}
+	}
+
+	return drift
+}
+
+// configSections flattens the indentation based hierarchy of the config,
+// prefixing every line with its parent sections, e.g. "interface Ethernet1 > description uplink".
+func configSections(cfg string) []string {
+	type section struct {
+		indent int
+		line   string
+	}
+
+	var (
+		stack []section
+		lines []string
+	)
+
+	for _, l := range strings.Split(cfg, "\n") {
+		t := strings.TrimSpace(l)
+
+		switch {
+		case t == "", t == "!", t == "}", t == "exit", t == "exit all", strings.HasPrefix(t, "#"):
+			continue
+		}
+
+		t = strings.TrimSuffix(strings.TrimSuffix(t, "{"), ";")
+		t = strings.TrimSpace(t)
+
+		indent := len(l) - len(strings.TrimLeft(l, " \t"))
+
+		for len(stack) != 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		parents := make([]string, 0, len(stack)+1)
+		for _, s := range stack {
+			parents = append(parents, s.line)
+		}
+
+		lines = append(lines, strings.Join(append(parents, t), " > "))
+
+		stack = append(stack, section{indent: indent, line: t})
+	}
+
+	return lines
+}
+
+// saveDriftDiffs saves the drift of every device to its output directory.
+func saveDriftDiffs(dir string, results []*checkResult) error {
+	for _, res := range results {
+		if res.Passed || res.Error != "" {
+			continue
+		}
+
+		devDir := path.Join(dir, res.Device)
+		if err := os.MkdirAll(devDir, filePermissions); err != nil {
+			return err
+		}
+
+		b := []byte(strings.Join(res.Details, "\n") + "\n")
+		if err := os.WriteFile(path.Join(devDir, driftFileName), b, filePermissions); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("drift has been saved to '%s' directory", dir)
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package commando
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/scrapli/scrapligocfg"
+)
+
+func TestConfigDrift(t *testing.T) {
+	tests := []struct {
+		name         string
+		intended     string
+		running      string
+		sectionAware bool
+		want         []string
+	}{
+		{
+			name:     "no drift",
+			intended: "hostname r1\nntp server 192.0.2.1\n",
+			running:  "hostname r1\nntp server 192.0.2.1\n",
+		},
+		{
+			name:     "trailing newlines",
+			intended: "hostname r1",
+			running:  "hostname r1\n\n",
+		},
+		{
+			name:     "changed line",
+			intended: "hostname r1\nntp server 192.0.2.1\n",
+			running:  "hostname r1\nntp server 192.0.2.2\n",
+			want: []string{
+				"--- intended",
+				"+++ running",
+				"@@ -1,2 +1,2 @@",
+				" hostname r1",
+				"-ntp server 192.0.2.1",
+				"+ntp server 192.0.2.2",
+			},
+		},
+		{
+			name:         "section aware ignores the order",
+			intended:     "interface Ethernet1\n   mtu 9000\ninterface Ethernet2\n   mtu 9000\n",
+			running:      "interface Ethernet2\n   mtu 9000\ninterface Ethernet1\n   mtu 9000\n",
+			sectionAware: true,
+		},
+		{
+			name:         "section aware",
+			intended:     "interface Ethernet1\n   mtu 9000\n!\nrouter bgp 65000\n",
+			running:      "interface Ethernet1\n   mtu 1500\n!\nrouter bgp 65000\n",
+			sectionAware: true,
+			want: []string{
+				"- interface Ethernet1 > mtu 9000",
+				"+ interface Ethernet1 > mtu 1500",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := configDrift(tt.intended, tt.running, tt.sectionAware); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigSections(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  string
+		want []string
+	}{
+		{
+			name: "indented sections",
+			cfg: `hostname r1
+interface Ethernet1
+   description uplink
+   ip address 10.0.0.1/31
+!
+router bgp 65000
+   neighbor 10.0.0.0 remote-as 65001
+   address-family ipv4
+      network 10.1.1.1/32
+   exit
+`,
+			want: []string{
+				"hostname r1",
+				"interface Ethernet1",
+				"interface Ethernet1 > description uplink",
+				"interface Ethernet1 > ip address 10.0.0.1/31",
+				"router bgp 65000",
+				"router bgp 65000 > neighbor 10.0.0.0 remote-as 65001",
+				"router bgp 65000 > address-family ipv4",
+				"router bgp 65000 > address-family ipv4 > network 10.1.1.1/32",
+			},
+		},
+		{
+			name: "curly braces",
+			cfg: `system {
+    host-name r1;
+    services {
+        ssh;
+    }
+}
+# comment
+`,
+			want: []string{
+				"system",
+				"system > host-name r1",
+				"system > services",
+				"system > services > ssh",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := configSections(tt.cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveIntendedConfig(t *testing.T) {
+	i := &inventory{
+		Groups: map[string]*group{
+			"leaf":   {IntendedConfig: "leaf.cfg"},
+			"border": {IntendedConfig: "border.cfg"},
+			"dc1":    {Vars: map[string]interface{}{"site": "dc1"}},
+		},
+		Devices: map[string]*device{
+			"leaf1":   {Groups: []string{"leaf", "dc1"}},
+			"leaf2":   {Groups: []string{"leaf"}, IntendedConfig: "leaf2.cfg"},
+			"border1": {Groups: []string{"leaf", "border"}},
+			"spine1":  {Groups: []string{"dc1"}},
+		},
+	}
+
+	if err := i.resolveVars(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"leaf1":   "leaf.cfg",
+		"leaf2":   "leaf2.cfg",
+		"border1": "border.cfg",
+		"spine1":  "",
+	}
+
+	for name, cfg := range want {
+		if got := i.Devices[name].IntendedConfig; got != cfg {
+			t.Errorf("device %s: got intended config %q, want %q", name, got, cfg)
+		}
+	}
+}
+
+func TestOfflineDrift(t *testing.T) {
+	dir := t.TempDir()
+
+	tpl := filepath.Join(dir, "leaf.cfg")
+	if err := os.WriteFile(tpl, []byte("hostname {{ .Name }}\nntp server {{ .Vars.ntp }}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	i := &inventory{
+		Vars:   map[string]interface{}{"ntp": "192.0.2.1"},
+		Groups: map[string]*group{"leaf": {IntendedConfig: tpl}},
+		Devices: map[string]*device{
+			"leaf1": {Platform: "arista_eos", Groups: []string{"leaf"}},
+			"leaf2": {Platform: "arista_eos", Groups: []string{"leaf"}},
+			"leaf3": {Platform: "arista_eos", Groups: []string{"leaf"}},
+			"r1":    {Platform: "arista_eos"},
+		},
+	}
+
+	if err := i.resolveVars(); err != nil {
+		t.Fatal(err)
+	}
+
+	outs := outputSet{
+		"leaf1": {scrapligocfg.GetConfig: "hostname leaf1\nntp server 192.0.2.1\n"},
+		"leaf2": {scrapligocfg.GetConfig: "hostname leaf2\nntp server 192.0.2.2\n"},
+		"r1":    {scrapligocfg.GetConfig: "hostname r1\n"},
+	}
+
+	app := &appCfg{sectionAware: true}
+
+	rep := app.offlineDrift(i.Devices, outs)
+
+	want := []*checkResult{
+		{Device: "leaf1", Check: driftCheckName, Passed: true},
+		{
+			Device:  "leaf2",
+			Check:   driftCheckName,
+			Details: []string{"- ntp server 192.0.2.1", "+ ntp server 192.0.2.2"},
+		},
+		{Device: "leaf3", Check: driftCheckName, Error: "no output collected for " + scrapligocfg.GetConfig},
+	}
+
+	if !reflect.DeepEqual(rep.Results, want) {
+		for _, r := range rep.Results {
+			t.Errorf("%+v", *r)
+		}
+
+		t.Fatal("drift results differ")
+	}
+}
@@ -0,0 +1,247 @@
+package commando
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	gitRunIDFormat = "20060102-150405"
+	gitRunIDSuffix = 0x10000 // upper bound of the random suffix of the run identifier
+	gitAuthorName  = "cmdo"
+	gitAuthorEmail = "cmdo@localhost"
+)
+
+// gitWriter writes the scrapli responses to a local git repository
+// and commits the results once per run.
+type gitWriter struct {
+	fw           *fileWriter
+	repo         string   // path to the git repository
+	branchPerRun bool     // create a new branch for every run
+	baseBranch   string   // branch the per-run branches start from
+	tagOnChange  bool     // tag the run's commit when outputs have changed
+	runID        string   // run identifier used in commit message, branch and tag names
+	failed       []string // devices that failed during the run
+	// devices connected with the fallback credentials mapped to the credentials names
+	fallbacks map[string]string
+}
+
+func (app *appCfg) newGitWriter() *gitWriter {
+	return &gitWriter{
+		fw:           &fileWriter{dir: app.gitRepo, norm: app.normalizer, keepRaw: app.keepRaw},
+		repo:         app.gitRepo,
+		branchPerRun: app.gitBranch,
+		baseBranch:   app.gitBaseBranch,
+		tagOnChange:  app.gitTag,
+		runID:        newGitRunID(),
+	}
+}
+
+// newGitRunID returns the run identifier made of the run's timestamp and a random suffix,
+// so that the runs started within the same second don't share the branch and tag names.
+func newGitRunID() string {
+	return fmt.Sprintf("%s-%04x", time.Now().Format(gitRunIDFormat), rand.Intn(gitRunIDSuffix)) //nolint:gosec
+}
+
+// checkoutBase checks out the base branch before the outputs are written,
+// so that the per-run branch starts from the base branch and not from the branch of the previous run.
+// The base branch of a new repository is born with an empty initial commit.
+func (w *gitWriter) checkoutBase() error {
+	if !w.branchPerRun {
+		return nil
+	}
+
+	if err := w.init(); err != nil {
+		return err
+	}
+
+	if _, err := w.git("rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		if _, err := w.git("symbolic-ref", "HEAD", "refs/heads/"+w.baseBranch); err != nil {
+			return err
+		}
+
+		_, err = w.git("commit", "--allow-empty", "-m", "cmdo: initialize the outputs repository")
+
+		return err
+	}
+
+	_, err := w.git("checkout", "--quiet", w.baseBranch)
+
+	return err
+}
+
+func (w *gitWriter) WriteResponse(r []interface{}, name string) error {
+	if r == nil {
+		// keep the outputs of the previous run for failed devices,
+		// so that the failure doesn't show up as deleted files in the history
+		w.failed = append(w.failed, name)
+
+		return nil
+	}
+
+	if err := checkDirName(name); err != nil {
+		return err
+	}
+
+	// outputs of the commands removed from the inventory should be removed
+	// from the repository as well
+	if err := os.RemoveAll(path.Join(w.repo, name)); err != nil {
+		return err
+	}
+
+	return w.fw.WriteResponse(r, name)
+}
+
+// Finalize commits all the outputs written during the run.
+func (w *gitWriter) Finalize() error {
+	if err := w.init(); err != nil {
+		return err
+	}
+
+	if w.branchPerRun {
+		if _, err := w.git("checkout", "-b", "run-"+w.runID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.git("add", "-A"); err != nil {
+		return err
+	}
+
+	changed, err := w.changedDevices()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.git("commit", "--allow-empty", "-m", w.commitMessage(changed)); err != nil {
+		return err
+	}
+
+	if w.tagOnChange && len(changed) != 0 {
+		if _, err := w.git("tag", "run-"+w.runID); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("run %s committed to '%s': %d device(s) changed, %d device(s) failed",
+		w.runID, w.repo, len(changed), len(w.failed))
+
+	return nil
+}
+
+// init initializes the git repository if it doesn't exist yet.
+func (w *gitWriter) init() error {
+	if err := os.MkdirAll(w.repo, filePermissions); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path.Join(w.repo, ".git")); err == nil {
+		return nil
+	}
+
+	_, err := w.git("init")
+
+	return err
+}
+
+// changedDevices returns the sorted list of devices which outputs are staged for commit.
+func (w *gitWriter) changedDevices() ([]string, error) {
+	out, err := w.git("diff", "--cached", "--name-only")
+	if err != nil {
+		return nil, err
+	}
+
+	devs := map[string]struct{}{}
+
+	for _, f := range strings.Split(out, "\n") {
+		// top-level files, such as reports, do not belong to any device
+		if !strings.Contains(f, "/") {
+			continue
+		}
+
+		devs[strings.SplitN(f, "/", 2)[0]] = struct{}{} //nolint:gomnd
+	}
+
+	changed := make([]string, 0, len(devs))
+	for d := range devs {
+		changed = append(changed, d)
+	}
+
+	sort.Strings(changed)
+
+	return changed, nil
+}
+
+func (w *gitWriter) commitMessage(changed []string) string {
+	sort.Strings(w.failed)
+
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "cmdo run %s: %d changed, %d failed\n",
+		w.runID, len(changed), len(w.failed))
+
+	if len(changed) != 0 {
+		fmt.Fprintf(b, "\nchanged:\n  %s\n", strings.Join(changed, "\n  "))
+	}
+
+	if len(w.failed) != 0 {
+		fmt.Fprintf(b, "\nfailed:\n  %s\n", strings.Join(w.failed, "\n  "))
+	}
+
+	if len(w.fallbacks) != 0 {
+		b.WriteString("\nfallback credentials:\n")
+
+		for _, dev := range sortedKeys(w.fallbacks) {
+			fmt.Fprintf(b, "  %s: %s\n", dev, w.fallbacks[dev])
+		}
+	}
+
+	return b.String()
+}
+
+// git runs the git binary with the given args in the repository directory
+// and returns its stdout.
+func (w *gitWriter) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = w.repo
+	cmd.Env = append(os.Environ(), gitIdentityEnv(w.repo)...)
+
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s",
+			strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// gitIdentityEnv returns a fallback commit identity for the environments
+// where the git user is not configured for the repository.
+func gitIdentityEnv(repo string) []string {
+	cmd := exec.Command("git", "config", "user.email")
+	cmd.Dir = repo
+
+	if out, err := cmd.Output(); err == nil && len(bytes.TrimSpace(out)) != 0 {
+		return nil
+	}
+
+	return []string{
+		"GIT_AUTHOR_NAME=" + gitAuthorName,
+		"GIT_AUTHOR_EMAIL=" + gitAuthorEmail,
+		"GIT_COMMITTER_NAME=" + gitAuthorName,
+		"GIT_COMMITTER_EMAIL=" + gitAuthorEmail,
+	}
+}
@@ -0,0 +1,136 @@
+package commando
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	cfgresponse "github.com/scrapli/scrapligocfg/response"
+)
+
+func newTestGitWriter(t *testing.T, repo string) *gitWriter {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	app := &appCfg{gitRepo: repo, gitBranch: true, gitBaseBranch: "main"}
+
+	return app.newGitWriter()
+}
+
+func configResponse(cfg string) []interface{} {
+	r := cfgresponse.NewResponse(getConfigOp, "192.0.2.1")
+	r.Result = cfg
+
+	return []interface{}{r}
+}
+
+func TestNewGitRunID(t *testing.T) {
+	re := regexp.MustCompile(`^\d{8}-\d{6}-[0-9a-f]{4}$`)
+	ids := map[string]struct{}{}
+
+	for i := 0; i < 10; i++ {
+		id := newGitRunID()
+		if !re.MatchString(id) {
+			t.Fatalf("run id %q doesn't match %s", id, re)
+		}
+
+		ids[id] = struct{}{}
+	}
+
+	if len(ids) == 1 {
+		t.Fatal("runs started within the same second got the same run id")
+	}
+}
+
+func TestGitWriterInvalidDeviceNames(t *testing.T) {
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "outputs")
+	w := newTestGitWriter(t, repo)
+
+	// a directory next to the repository which must survive the writes
+	keep := filepath.Join(dir, "keep")
+	if err := os.Mkdir(keep, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"", ".", "..", "../keep", "r1/..", "a/b", `a\b`, "r1/", "./r1"} {
+		if err := w.WriteResponse(configResponse("hostname r1"), name); !errors.Is(err, errInvalidDeviceName) {
+			t.Errorf("device name %q: got error %v, want %v", name, err, errInvalidDeviceName)
+		}
+	}
+
+	if _, err := os.Stat(keep); err != nil {
+		t.Fatalf("directory outside of the repository was removed: %v", err)
+	}
+
+	if err := w.WriteResponse(configResponse("hostname r1"), "r1.dc1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGitWriterBranchPerRun(t *testing.T) {
+	repo := filepath.Join(t.TempDir(), "outputs")
+
+	var runs []*gitWriter
+
+	for _, cfg := range []string{"hostname r1", "hostname r1-new"} {
+		w := newTestGitWriter(t, repo)
+
+		if err := w.checkoutBase(); err != nil {
+			t.Fatal(err)
+		}
+
+		if head, _ := w.git("rev-parse", "--abbrev-ref", "HEAD"); strings.TrimSpace(head) != "main" {
+			t.Fatalf("run starts on branch %q, want main", strings.TrimSpace(head))
+		}
+
+		if err := w.WriteResponse(configResponse(cfg), "r1"); err != nil {
+			t.Fatal(err)
+		}
+
+		w.failed = []string{"r2"}
+
+		if err := w.Finalize(); err != nil {
+			t.Fatal(err)
+		}
+
+		runs = append(runs, w)
+	}
+
+	w := runs[1]
+
+	if runs[0].runID == w.runID {
+		t.Fatalf("both runs got the run id %s", w.runID)
+	}
+
+	// both run branches start from the base branch rather than from each other
+	base, _ := w.git("rev-parse", "main")
+
+	for _, r := range runs {
+		parent, err := w.git("rev-parse", "run-"+r.runID+"^")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parent != base {
+			t.Errorf("branch run-%s starts from %s, want the base branch commit %s", r.runID, parent, base)
+		}
+	}
+
+	msg, err := w.git("log", "-1", "--format=%B", "run-"+w.runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "cmdo run " + w.runID + ": 1 changed, 1 failed\n\nchanged:\n  r1\n\nfailed:\n  r2\n"
+	if strings.TrimSpace(msg) != strings.TrimSpace(want) {
+		t.Fatalf("got commit message:\n%s\nwant:\n%s", msg, want)
+	}
+}
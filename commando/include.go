@@ -0,0 +1,187 @@
+package commando
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	yamlv2 "gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
+)
+
+// inventoryFile is one of the files the inventory is merged from.
+type inventoryFile struct {
+	path     string
+	b        []byte     // decrypted contents of the file
+	root     *yaml.Node // root node of the document, nil if the file is empty or can't be parsed
+	parseErr error
+	// the file is converted from the ansible inventory, its lines don't match the original file
+	generated bool
+	splice    *credentialsSplice // decrypted credentials section, nil if the section is not encrypted
+}
+
+// readInventoryFiles reads the inventory files set with --inventory and the files they include,
+// followed by the ansible inventory set with --ansible-inventory.
+// The files are returned in the order they are merged: every file follows the files it includes,
+// which are read in the order of the include patterns and their sorted matches.
+// A file included more than once is read once.
+func (app *appCfg) readInventoryFiles() ([]*inventoryFile, error) {
+	r := &inventoryReader{app: app, seen: map[string]bool{}}
+
+	for _, p := range app.inventories {
+		if err := r.read(p); err != nil {
+			return nil, err
+		}
+	}
+
+	if app.ansibleInv != "" {
+		f, err := app.readAnsibleInventory(r.files)
+		if err != nil {
+			return nil, err
+		}
+
+		r.files = append(r.files, f)
+	}
+
+	return r.files, nil
+}
+
+type inventoryReader struct {
+	app   *appCfg
+	seen  map[string]bool
+	files []*inventoryFile
+}
+
+func (r *inventoryReader) read(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	if r.seen[abs] {
+		return nil
+	}
+
+	r.seen[abs] = true
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	b, splice, err := r.app.decryptInventory(b)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	f := &inventoryFile{path: path, b: b, splice: splice}
+
+	doc := &yaml.Node{}
+	if f.parseErr = yaml.Unmarshal(b, doc); f.parseErr == nil && len(doc.Content) != 0 {
+		// the problems are reported at the lines of the file rather than of the decrypted inventory
+		splice.remap(doc)
+		f.root = resolveAlias(doc.Content[0])
+	}
+
+	if _, inc := mappingValue(f.root, "include"); inc != nil {
+		for _, pattern := range scalars(inc) {
+			matches, err := includedFiles(path, pattern.Value)
+			if err != nil {
+				return fmt.Errorf("%s:%d:%d: include: %w", path, pattern.Line, pattern.Column, err)
+			}
+
+			for _, m := range matches {
+				if err := r.read(m); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	r.files = append(r.files, f)
+
+	return nil
+}
+
+// remap maps the positions of the node n of the spliced inventory and its children
+// back to the positions in the file.
+func (s *credentialsSplice) remap(n *yaml.Node) {
+	if s == nil {
+		return
+	}
+
+	if n.Line > s.start && n.Line < s.start+s.lines {
+		n.Column = 1
+	}
+
+	n.Line = s.fileLine(n.Line)
+
+	for _, c := range n.Content {
+		s.remap(c)
+	}
+}
+
+// includedFiles returns the sorted files matching the include pattern of the from file.
+// The relative patterns are relative to the directory of the from file.
+// The pattern without the glob characters must match an existing file.
+func includedFiles(from, pattern string) ([]string, error) {
+	p := pattern
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(filepath.Dir(from), p)
+	}
+
+	matches, err := filepath.Glob(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+		return nil, fmt.Errorf("%w: %q", errIncludeNotFound, pattern)
+	}
+
+	sort.Strings(matches)
+
+	return matches, nil
+}
+
+// mergeInventory merges the inventory files into i. The credentials, transports, devices, groups
+// and vars are merged by their names, the first definition is kept as the conflicting definitions
+// are reported by the validation. The normalisation rules are appended in the order of the files.
+func mergeInventory(i *inventory, files []*inventoryFile) error {
+	for _, f := range files {
+		part := &inventory{}
+		if err := yamlv2.UnmarshalStrict(f.b, part); err != nil {
+			return fmt.Errorf("%s: %w", f.path, err)
+		}
+
+		i.Credentials = mergeMaps(i.Credentials, part.Credentials)
+		i.Transports = mergeMaps(i.Transports, part.Transports)
+		i.Devices = mergeMaps(i.Devices, part.Devices)
+		i.Groups = mergeMaps(i.Groups, part.Groups)
+		i.Vars = mergeMaps(i.Vars, part.Vars)
+		i.Normalize = append(i.Normalize, part.Normalize...)
+	}
+
+	return nil
+}
+
+// mergeMaps adds the src elements not defined in dst to dst.
+func mergeMaps[T any](dst, src map[string]T) map[string]T {
+	if len(src) == 0 {
+		return dst
+	}
+
+	if dst == nil {
+		dst = map[string]T{}
+	}
+
+	for k, v := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = v
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,129 @@
+package commando
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeInventories writes the files to the temporary directory and reads the first one
+// with the files it includes.
+func writeInventories(t *testing.T, files map[string]string, main ...string) (string, []*inventoryFile) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, data := range files {
+		p := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(p, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := &appCfg{}
+	for _, m := range main {
+		app.inventories = append(app.inventories, filepath.Join(dir, m))
+	}
+
+	fs, err := app.readInventoryFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir, fs
+}
+
+func TestMergeInventory(t *testing.T) {
+	dir, files := writeInventories(t, map[string]string{
+		"inventory.yml": `include:
+  - common.yml
+  - devices/*.yml
+credentials:
+  default:
+    username: admin
+vars:
+  site: dc1
+normalize:
+  - drop-lines: [main]
+`,
+		"common.yml": `credentials:
+  default:
+    username: common
+  ro:
+    username: ro
+vars:
+  site: common
+  ntp: 192.0.2.123
+normalize:
+  - drop-lines: [common]
+`,
+		"devices/a.yml": `devices:
+  r1:
+    platform: arista_eos
+    address: 192.0.2.1
+`,
+		"devices/b.yml": `devices:
+  r1:
+    platform: cisco_iosxe
+    address: 192.0.2.100
+  r2:
+    platform: cisco_iosxe
+    address: 192.0.2.2
+`,
+	}, "inventory.yml", "devices/a.yml")
+
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, strings.TrimPrefix(f.path, dir+"/"))
+	}
+
+	// the included files come first and the file included twice is read once
+	wantPaths := []string{"common.yml", "devices/a.yml", "devices/b.yml", "inventory.yml"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Fatalf("got files %q, want %q", paths, wantPaths)
+	}
+
+	i := &inventory{}
+	if err := mergeInventory(i, files); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"default credentials", i.Credentials[defaultName].Username, "common"},
+		{"ro credentials", i.Credentials["ro"].Username, "ro"},
+		{"r1 platform", i.Devices["r1"].Platform, "arista_eos"},
+		{"r2 address", i.Devices["r2"].Address, "192.0.2.2"},
+		{"vars", i.Vars, map[string]interface{}{"site": "common", "ntp": "192.0.2.123"}},
+		{"normalize", [][]string{i.Normalize[0].DropLines, i.Normalize[1].DropLines}, [][]string{{"common"}, {"main"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Fatalf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeInventoryUnknownField(t *testing.T) {
+	_, files := writeInventories(t, map[string]string{
+		"inventory.yml": "devices:\n  r1:\n    platfrom: arista_eos\n",
+	}, "inventory.yml")
+
+	err := mergeInventory(&inventory{}, files)
+	if err == nil || !strings.Contains(err.Error(), "inventory.yml") {
+		t.Fatalf("got error %v, want the unknown field error of inventory.yml", err)
+	}
+}
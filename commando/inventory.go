@@ -1,26 +1,38 @@
 package commando
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
 
-	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 )
 
 func (app *appCfg) loadInventoryFromYAML(i *inventory) error {
-	yamlFile, err := os.ReadFile(app.inventory)
+	files, err := app.readInventoryFiles()
 	if err != nil {
 		return err
 	}
 
-	err = yaml.UnmarshalStrict(yamlFile, i)
-	if err != nil {
-		log.Fatal(err)
+	// the whole inventory is validated, so that all its problems are reported at once
+	if err := app.checkInventory(files); err != nil {
+		return err
+	}
+
+	if err := mergeInventory(i, files); err != nil {
+		return err
 	}
 
 	filterDevices(i, app.devFilter)
+	filterGroups(i, app.devGroups)
+
+	app.normalizer, err = newNormalizer(i.Normalize, i.Devices)
+	if err != nil {
+		return err
+	}
 
 	if len(i.Devices) == 0 {
 		return errNoDevices
@@ -29,16 +41,32 @@ func (app *appCfg) loadInventoryFromYAML(i *inventory) error {
 	app.credentials = i.Credentials
 	app.transports = i.Transports
 
-	// user-provided commands (via cli flag) take precedence over inventory
-	if app.commands != "" {
-		cmds := strings.Split(app.commands, "::")
+	// user-provided commands and configs (via cli flags) take precedence over inventory
+	cmds, err := app.cliCommands()
+	if err != nil {
+		return err
+	}
 
-		for _, device := range i.Devices {
-			device.SendCommands = cmds
+	cfgs, err := app.cliConfigs()
+	if err != nil {
+		return err
+	}
+
+	for _, device := range i.Devices {
+		if cmds != nil {
+			device.SendCommands, device.SendCommandsFromFile = cmds, ""
+		}
+
+		if cfgs != nil {
+			device.SendConfigs, device.SendConfigsFromFile = cfgs, ""
 		}
 	}
 
-	return nil
+	if err := i.resolveVars(); err != nil {
+		return err
+	}
+
+	return renderDevices(i.Devices)
 }
 
 func (app *appCfg) loadInventoryFromFlags(i *inventory) error {
@@ -50,11 +78,24 @@ func (app *appCfg) loadInventoryFromFlags(i *inventory) error {
 		return errNoUsernameDefined
 	}
 
-	if app.password == "" {
-		return errNoPasswordDefined
+	// the password is optional when authenticating with the private key
+	if app.privateKey == "" || app.password != "" || app.passwdStdin {
+		if err := app.readPassword(); err != nil {
+			return err
+		}
+	}
+
+	cmds, err := app.cliCommands()
+	if err != nil {
+		return err
 	}
 
-	if app.commands == "" {
+	cfgs, err := app.cliConfigs()
+	if err != nil {
+		return err
+	}
+
+	if cmds == nil && cfgs == nil && len(app.cfgOps) == 0 {
 		return errNoCommandsDefined
 	}
 
@@ -63,20 +104,221 @@ func (app *appCfg) loadInventoryFromFlags(i *inventory) error {
 			Username:          app.username,
 			Password:          app.password,
 			SecondaryPassword: app.password,
+			PrivateKey:        app.privateKey,
 		},
 	}
 
-	cmds := strings.Split(app.commands, "::")
+	// the transport flags make the default transport, like the transports of the inventory do.
+	// The host keys are checked unless --no-strict-key is set, as they are without the transport flags.
+	if app.port != 0 || app.transportType != "" || app.sshConfigFile != "" || app.noStrictKey {
+		app.transports = map[string]*transports{
+			defaultName: {
+				Port:          app.port,
+				StrictKey:     !app.noStrictKey,
+				SSHConfigFile: app.sshConfigFile,
+				TransportType: app.transportType,
+			},
+		}
+	}
 
 	i.Devices = map[string]*device{}
 
-	i.Devices[app.address] = &device{
-		Platform:     app.platform,
-		Address:      app.address,
-		SendCommands: cmds,
+	for _, addr := range strings.Split(app.address, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+
+		ops := make([]*cfgOperation, 0, len(app.cfgOps))
+
+		// every device gets its own operations, as rendering and the transactions update them
+		for _, s := range app.cfgOps {
+			op, err := parseCfgOperation(s)
+			if err != nil {
+				return err
+			}
+
+			ops = append(ops, op)
+		}
+
+		i.Devices[addr] = &device{
+			Platform:      app.platform,
+			Address:       addr,
+			SendCommands:  cmds,
+			SendConfigs:   cfgs,
+			CfgOperations: ops,
+		}
+	}
+
+	if len(i.Devices) == 0 {
+		return errNoDevices
+	}
+
+	if err := validateCfgOperations(i.Devices); err != nil {
+		return err
+	}
+
+	// the commands and configs are templates in the single-node mode as well
+	return renderDevices(i.Devices)
+}
+
+// cliCommands returns the commands set with the cli flags: the `::` delimited --commands,
+// or the commands read from stdin when it is set to "-", followed by the --cmd commands
+// and the commands of the --commands-file. Nil is returned when none of the flags is set.
+func (app *appCfg) cliCommands() ([]string, error) {
+	var cmds []string
+
+	switch app.commands {
+	case "":
+	case stdinArg:
+		if app.passwdStdin || app.confirm {
+			return nil, errStdinInUse
+		}
+
+		lines, err := readLines(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("stdin: %w", err)
+		}
+
+		if len(lines) == 0 {
+			return nil, errNoCommandsDefined
+		}
+
+		cmds = lines
+	default:
+		cmds = strings.Split(app.commands, "::")
 	}
 
-	return nil
+	cmds = append(cmds, app.cmds...)
+
+	if app.commandsFile != "" {
+		f, err := os.Open(app.commandsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		defer f.Close()
+
+		lines, err := readLines(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", app.commandsFile, err)
+		}
+
+		cmds = append(cmds, lines...)
+	}
+
+	return cmds, nil
+}
+
+// cliConfigs returns the configs set with the cli flags: the `::` delimited --configs,
+// followed by the configs of the --configs-file. Nil is returned when none of the flags is set.
+func (app *appCfg) cliConfigs() ([]string, error) {
+	var cfgs []string
+
+	if app.configs != "" {
+		cfgs = strings.Split(app.configs, "::")
+	}
+
+	if app.configsFile != "" {
+		f, err := os.Open(app.configsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		defer f.Close()
+
+		lines, err := readLines(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", app.configsFile, err)
+		}
+
+		cfgs = append(cfgs, lines...)
+	}
+
+	return cfgs, nil
+}
+
+// readLines reads the lines of r skipping the blank ones.
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if l := strings.TrimRight(scanner.Text(), "\r"); strings.TrimSpace(l) != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// parseCfgOperation parses the cfg operation set with the --cfg-operation flag.
+// The operation is set as its type followed by the comma separated fields of the inventory's
+// cfg operation, e.g. load-config,config-from-file=new.cfg,diff,commit.
+// The fields set without a value are set to true.
+func parseCfgOperation(s string) (*cfgOperation, error) {
+	parts := strings.Split(s, ",")
+
+	fields := map[string]interface{}{"type": strings.TrimSpace(parts[0])}
+
+	for _, p := range parts[1:] {
+		k, v, ok := strings.Cut(p, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+
+		if !ok {
+			fields[k] = true
+
+			continue
+		}
+
+		// the booleans and numbers are kept typed, anything else is a string
+		var val interface{}
+		if err := yaml.Unmarshal([]byte(v), &val); err == nil {
+			switch val.(type) {
+			case bool, int, float64:
+				fields[k] = val
+
+				continue
+			}
+		}
+
+		fields[k] = v
+	}
+
+	b, err := yaml.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	op := &cfgOperation{}
+	if err := yaml.UnmarshalStrict(b, op); err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", errInvalidCfgOperation, s, err)
+	}
+
+	return op, nil
+}
+
+// filterGroups will remove the devices which do not belong to any of the passed groups.
+func filterGroups(i *inventory, groups []string) {
+	if len(groups) == 0 {
+		return
+	}
+
+	for n, d := range i.Devices {
+		selected := false
+
+		for _, g := range d.Groups {
+			if contains(groups, g) {
+				selected = true
+
+				break
+			}
+		}
+
+		if !selected {
+			delete(i.Devices, n)
+		}
+	}
 }
 
 // filterDevices will remove the devices which names do not match the passed filter.
@@ -0,0 +1,241 @@
+package commando
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCfgOperation(t *testing.T) {
+	tests := []struct {
+		op      string
+		want    *cfgOperation
+		wantErr error
+	}{
+		{
+			op:   "get-config",
+			want: &cfgOperation{OperationType: getConfigOp},
+		},
+		{
+			op:   "get-config,source=startup",
+			want: &cfgOperation{OperationType: getConfigOp, Source: "startup"},
+		},
+		{
+			op: "load-config,config-from-file=new.cfg,replace,diff,commit",
+			want: &cfgOperation{
+				OperationType:  loadConfigOp,
+				ConfigFromFile: "new.cfg",
+				Replace:        true,
+				Diff:           true,
+				Commit:         true,
+			},
+		},
+		{
+			op:   " load-config , config-from-file=new.cfg , replace=false",
+			want: &cfgOperation{OperationType: loadConfigOp, ConfigFromFile: "new.cfg"},
+		},
+		{
+			op: "load-config,config-from-file=new.cfg,commit,rollback-on-failure,settle=30s,commit-confirmed=5m",
+			want: &cfgOperation{
+				OperationType:     loadConfigOp,
+				ConfigFromFile:    "new.cfg",
+				Commit:            true,
+				RollbackOnFailure: true,
+				Settle:            30 * time.Second,
+				CommitConfirmed:   5 * time.Minute,
+			},
+		},
+		{
+			op:   "rollback,checkpoint=3",
+			want: &cfgOperation{OperationType: rollbackToOp, Checkpoint: "3"},
+		},
+		{
+			op:   "load-config,config=hostname r1",
+			want: &cfgOperation{OperationType: loadConfigOp, Config: "hostname r1"},
+		},
+		{
+			op:      "load-config,config-file=new.cfg",
+			wantErr: errInvalidCfgOperation,
+		},
+		{
+			op:      "load-config,commit=yes please",
+			wantErr: errInvalidCfgOperation,
+		},
+		{
+			op:      "load-config,settle=soon",
+			wantErr: errInvalidCfgOperation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.op, func(t *testing.T) {
+			got, err := parseCfgOperation(tt.op)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadInventoryFromFlagsTransport(t *testing.T) {
+	tests := []struct {
+		name string
+		app  appCfg
+		want map[string]*transports
+	}{
+		{
+			name: "no transport flags",
+		},
+		{
+			name: "port keeps the host keys checked",
+			app:  appCfg{port: 2222},
+			want: map[string]*transports{defaultName: {Port: 2222, StrictKey: true}},
+		},
+		{
+			name: "ssh config file keeps the host keys checked",
+			app:  appCfg{transportType: "system", sshConfigFile: "ssh_config"},
+			want: map[string]*transports{
+				defaultName: {TransportType: "system", SSHConfigFile: "ssh_config", StrictKey: true},
+			},
+		},
+		{
+			name: "no strict key",
+			app:  appCfg{noStrictKey: true},
+			want: map[string]*transports{defaultName: {}},
+		},
+		{
+			name: "port and no strict key",
+			app:  appCfg{port: 2222, noStrictKey: true},
+			want: map[string]*transports{defaultName: {Port: 2222}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := tt.app
+			app.platform, app.address, app.username, app.password = "arista_eos", "192.0.2.1", "admin", "admin"
+			app.commands = "show version"
+
+			if err := app.loadInventoryFromFlags(&inventory{}); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(app.transports, tt.want) {
+				t.Fatalf("got transports %+v, want %+v", app.transports[defaultName], tt.want[defaultName])
+			}
+		})
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	got, err := readLines(strings.NewReader("show version\r\n\n   \nshow ip route vrf MGMT\n  show clock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"show version", "show ip route vrf MGMT", "  show clock"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestCliCommands(t *testing.T) {
+	dir := t.TempDir()
+
+	cmdsFile := filepath.Join(dir, "commands.txt")
+	if err := os.WriteFile(cmdsFile, []byte("show clock\n\nshow lldp neighbors\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		app     *appCfg
+		stdin   string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "not set",
+			app:  &appCfg{},
+		},
+		{
+			name: "flags and file in order",
+			app:  &appCfg{commands: "show version::show uptime", cmds: []string{"show ip int brief"}, commandsFile: cmdsFile},
+			want: []string{"show version", "show uptime", "show ip int brief", "show clock", "show lldp neighbors"},
+		},
+		{
+			name:  "stdin",
+			app:   &appCfg{commands: stdinArg, cmds: []string{"show clock"}},
+			stdin: "show version\n\nshow uptime\n",
+			want:  []string{"show version", "show uptime", "show clock"},
+		},
+		{
+			name:    "empty stdin",
+			app:     &appCfg{commands: stdinArg},
+			wantErr: errNoCommandsDefined,
+		},
+		{
+			name:    "stdin holds the password",
+			app:     &appCfg{commands: stdinArg, passwdStdin: true},
+			stdin:   "s3cret\nshow version\n",
+			wantErr: errStdinInUse,
+		},
+		{
+			name:    "stdin holds the confirm answers",
+			app:     &appCfg{commands: stdinArg, confirm: true},
+			stdin:   "show version\n",
+			wantErr: errStdinInUse,
+		},
+		{
+			name:    "missing file",
+			app:     &appCfg{commandsFile: filepath.Join(dir, "missing.txt")},
+			wantErr: os.ErrNotExist,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withStdin(t, tt.stdin)
+
+			got, err := tt.app.cliCommands()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCliConfigs(t *testing.T) {
+	cfgsFile := filepath.Join(t.TempDir(), "configs.txt")
+	if err := os.WriteFile(cfgsFile, []byte("interface Ethernet1\n   description uplink\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &appCfg{configs: "hostname r1::ip routing", configsFile: cfgsFile}
+
+	got, err := app.cliConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"hostname r1", "ip routing", "interface Ethernet1", "   description uplink"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	if got, err := (&appCfg{}).cliConfigs(); got != nil || err != nil {
+		t.Fatalf("got configs %q and error %v without the flags", got, err)
+	}
+}
@@ -0,0 +1,87 @@
+package commando
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+)
+
+const (
+	manifestFile = "manifest.json"
+
+	deviceStatusOK     = "ok"
+	deviceStatusFailed = "failed"
+)
+
+// runManifest describes the outcome of the run per device.
+type runManifest struct {
+	Devices []*manifestDevice `json:"devices"`
+}
+
+type manifestDevice struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// credentials the device was connected with.
+	Credentials string `json:"credentials,omitempty"`
+	// true if the credentials are not the first ones of the device's credentials chain.
+	Fallback bool `json:"fallback,omitempty"`
+	// error the run failed with for the device.
+	Error string `json:"error,omitempty"`
+}
+
+// newRunManifest returns the manifest of the run for the devices,
+// the devices without the collected outputs and the devices the run failed for,
+// e.g. the ones which configs were rolled back, are considered failed.
+func (app *appCfg) newRunManifest(devs map[string]*device) *runManifest {
+	m := &runManifest{}
+
+	for _, name := range sortedKeys(devs) {
+		md := &manifestDevice{Name: name, Status: deviceStatusFailed}
+
+		if _, ok := app.outputs[name]; ok {
+			md.Status = deviceStatusOK
+		}
+
+		if err := app.failures[name]; err != nil {
+			md.Status, md.Error = deviceStatusFailed, err.Error()
+		}
+
+		md.Credentials, md.Fallback = app.usedCreds.get(name)
+
+		m.Devices = append(m.Devices, md)
+	}
+
+	return m
+}
+
+// fallbacks returns the devices connected with the fallback credentials
+// mapped to the credentials names.
+func (m *runManifest) fallbacks() map[string]string {
+	f := map[string]string{}
+
+	for _, d := range m.Devices {
+		if d.Fallback {
+			f[d.Name] = d.Credentials
+		}
+	}
+
+	return f
+}
+
+// saveManifest writes the manifest to the output directory, if the output is file based.
+func (app *appCfg) saveManifest(m *runManifest) error {
+	if app.outDir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(app.outDir, filePermissions); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path.Join(app.outDir, manifestFile), append(b, '\n'), filePermissions)
+}
@@ -0,0 +1,193 @@
+package commando
+
+import (
+	"regexp"
+	"strings"
+)
+
+const rawOutputDir = "raw"
+
+// normalizeRule defines how to normalise the volatile parts of the outputs,
+// such as uptime, counters and timestamps, before they are saved or diffed.
+type normalizeRule struct {
+	// platforms the rule applies to. Applies to all platforms when empty.
+	Platforms []string `yaml:"platforms,omitempty"`
+	// commands which outputs the rule applies to. Applies to all outputs when empty.
+	// cfg operations are referred to by their name, e.g. GetConfig.
+	Commands []string `yaml:"commands,omitempty"`
+	// lines matching any of these patterns are removed from the output.
+	DropLines []string `yaml:"drop-lines,omitempty"`
+	// replace operations applied to the output in order.
+	Replace []*replaceRule `yaml:"replace,omitempty"`
+}
+
+type replaceRule struct {
+	Pattern string `yaml:"pattern,omitempty"`
+	With    string `yaml:"with,omitempty"`
+}
+
+type compiledNormalizeRule struct {
+	platforms map[string]struct{}
+	files     map[string]struct{}
+	dropLines []*regexp.Regexp
+	replace   []*regexp.Regexp
+	with      []string
+}
+
+// normalizer applies the normalisation rules to the outputs of the devices.
+// A nil normalizer leaves the outputs intact.
+type normalizer struct {
+	rules     []*compiledNormalizeRule
+	platforms map[string]string // device name -> platform
+}
+
+func newNormalizer(rules []*normalizeRule, devs map[string]*device) (*normalizer, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	n := &normalizer{
+		platforms: map[string]string{},
+	}
+
+	for name, d := range devs {
+		n.platforms[name] = d.Platform
+	}
+
+	for _, r := range rules {
+		cr := &compiledNormalizeRule{
+			platforms: map[string]struct{}{},
+			files:     map[string]struct{}{},
+		}
+
+		for _, p := range r.Platforms {
+			cr.platforms[p] = struct{}{}
+		}
+
+		// outputs are referred to by the names of the files they are saved in
+		for _, c := range r.Commands {
+			cr.files[sanitizeFileName(c)] = struct{}{}
+		}
+
+		for _, p := range r.DropLines {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, err
+			}
+
+			cr.dropLines = append(cr.dropLines, re)
+		}
+
+		for _, rr := range r.Replace {
+			re, err := regexp.Compile(rr.Pattern)
+			if err != nil {
+				return nil, err
+			}
+
+			cr.replace = append(cr.replace, re)
+			cr.with = append(cr.with, rr.With)
+		}
+
+		n.rules = append(n.rules, cr)
+	}
+
+	return n, nil
+}
+
+// loadNormalizer loads the normalisation rules from the inventory file if it exists.
+func (app *appCfg) loadNormalizer() error {
+	i, err := app.loadInventoryFile()
+	if err != nil || i == nil {
+		return err
+	}
+
+	app.normalizer, err = newNormalizer(i.Normalize, i.Devices)
+
+	return err
+}
+
+func (r *compiledNormalizeRule) matches(platform, file string) bool {
+	if len(r.platforms) != 0 {
+		if _, ok := r.platforms[platform]; !ok {
+			return false
+		}
+	}
+
+	if len(r.files) != 0 {
+		if _, ok := r.files[file]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// apply returns the normalised output s of the file output of the dev device.
+func (n *normalizer) apply(dev, file, s string) string {
+	if n == nil {
+		return s
+	}
+
+	for _, r := range n.rules {
+		if !r.matches(n.platforms[dev], file) {
+			continue
+		}
+
+		if len(r.dropLines) != 0 {
+			lines := strings.Split(s, "\n")
+			kept := lines[:0]
+
+			for _, l := range lines {
+				if !matchesAny(r.dropLines, l) {
+					kept = append(kept, l)
+				}
+			}
+
+			s = strings.Join(kept, "\n")
+		}
+
+		for i, re := range r.replace {
+			s = re.ReplaceAllString(s, r.with[i])
+		}
+	}
+
+	return s
+}
+
+// applyDevice returns the normalised outputs of the dev device.
+func (n *normalizer) applyDevice(dev string, outs map[string]string) map[string]string {
+	if n == nil {
+		return outs
+	}
+
+	norm := make(map[string]string, len(outs))
+	for f, s := range outs {
+		norm[f] = n.apply(dev, f, s)
+	}
+
+	return norm
+}
+
+// applySet returns the normalised outputs of all devices in the o output set.
+func (n *normalizer) applySet(o outputSet) outputSet {
+	if n == nil {
+		return o
+	}
+
+	norm := make(outputSet, len(o))
+	for dev, outs := range o {
+		norm[dev] = n.applyDevice(dev, outs)
+	}
+
+	return norm
+}
+
+func matchesAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,120 @@
+package commando
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizer(t *testing.T) {
+	devs := map[string]*device{
+		"eos1": {Platform: "arista_eos"},
+		"xr1":  {Platform: "cisco_iosxr"},
+	}
+
+	n, err := newNormalizer([]*normalizeRule{
+		{
+			DropLines: []string{`^Last configuration change`},
+		},
+		{
+			Platforms: []string{"arista_eos"},
+			Commands:  []string{"show version"},
+			Replace: []*replaceRule{
+				{Pattern: `Uptime: .*`, With: "Uptime: <uptime>"},
+				{Pattern: `<uptime>`, With: "<redacted>"},
+			},
+		},
+	}, devs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := "Last configuration change at 10:00\nUptime: 3 weeks\nmodel: 7050"
+
+	tests := []struct {
+		name string
+		dev  string
+		file string
+		want string
+	}{
+		{"all rules", "eos1", "show-version", "Uptime: <redacted>\nmodel: 7050"},
+		{"other command", "eos1", "show-clock", "Uptime: 3 weeks\nmodel: 7050"},
+		{"other platform", "xr1", "show-version", "Uptime: 3 weeks\nmodel: 7050"},
+		{"unknown device", "r9", "show-version", "Uptime: 3 weeks\nmodel: 7050"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := n.apply(tt.dev, tt.file, out); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	set := outputSet{"eos1": {"show-version": out}}
+	want := outputSet{"eos1": {"show-version": "Uptime: <redacted>\nmodel: 7050"}}
+
+	if got := n.applySet(set); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	if set["eos1"]["show-version"] != out {
+		t.Fatal("normalisation modified the raw outputs")
+	}
+}
+
+func TestNilNormalizer(t *testing.T) {
+	n, err := newNormalizer(nil, nil)
+	if err != nil || n != nil {
+		t.Fatalf("got normalizer %v and error %v without the rules", n, err)
+	}
+
+	set := outputSet{"r1": {"show-version": "Uptime: 3 weeks"}}
+	if got := n.applySet(set); !reflect.DeepEqual(got, set) {
+		t.Fatalf("got %q, want %q", got, set)
+	}
+}
+
+func TestNewNormalizerInvalidPattern(t *testing.T) {
+	for _, r := range []*normalizeRule{
+		{DropLines: []string{"("}},
+		{Replace: []*replaceRule{{Pattern: "[a-"}}},
+	} {
+		if _, err := newNormalizer([]*normalizeRule{r}, nil); err == nil {
+			t.Errorf("rule %+v compiled without an error", *r)
+		}
+	}
+}
+
+func TestFileWriterKeepRaw(t *testing.T) {
+	n, err := newNormalizer([]*normalizeRule{
+		{Replace: []*replaceRule{{Pattern: `\d+ weeks`, With: "<uptime>"}}},
+	}, map[string]*device{"r1": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	w := &fileWriter{dir: dir, norm: n, keepRaw: true}
+
+	if err := w.writeOutputs("r1", map[string]string{"show-version": "Uptime: 3 weeks"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		filepath.Join(dir, "r1", "show-version"):               "Uptime: <uptime>",
+		filepath.Join(dir, "r1", rawOutputDir, "show-version"): "Uptime: 3 weeks",
+	}
+
+	for f, s := range want {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != s {
+			t.Errorf("%s: got %q, want %q", f, b, s)
+		}
+	}
+}
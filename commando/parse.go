@@ -0,0 +1,179 @@
+package commando
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/scrapli/scrapligo/util"
+)
+
+const (
+	matchAll  = "all"
+	matchAny  = "any"
+	matchNone = "none"
+)
+
+// fieldCondition is a condition evaluated on a field of the records
+// parsed from an output with a textfsm template.
+type fieldCondition struct {
+	// path or URL of the textfsm template used to parse the output.
+	Template string `yaml:"template,omitempty"`
+	// name of the parsed field, as defined by the Value in the template.
+	Field string `yaml:"field,omitempty"`
+	// one of ==, !=, >, >=, <, <=, contains, matches.
+	Op string `yaml:"op,omitempty"`
+	// value to compare the field with.
+	Value string `yaml:"value,omitempty"`
+	// which records must satisfy the condition: all (default), any or none.
+	Match string `yaml:"match,omitempty"`
+}
+
+var fieldOps = map[string]struct{}{ //nolint:gochecknoglobals
+	"==": {}, "!=": {}, ">": {}, ">=": {}, "<": {}, "<=": {}, "contains": {}, "matches": {},
+}
+
+func (c *fieldCondition) validate() error {
+	if c.Template == "" || c.Field == "" {
+		return fmt.Errorf("%w: template and field must be set", errInvalidFieldCondition)
+	}
+
+	if _, ok := fieldOps[c.Op]; !ok {
+		return fmt.Errorf("%w: unknown operator %q", errInvalidFieldCondition, c.Op)
+	}
+
+	switch c.Match {
+	case "", matchAll, matchAny, matchNone:
+	default:
+		return fmt.Errorf("%w: unknown match %q", errInvalidFieldCondition, c.Match)
+	}
+
+	if c.Op == "matches" {
+		if _, err := regexp.Compile(c.Value); err != nil {
+			return fmt.Errorf("%w: %v", errInvalidFieldCondition, err)
+		}
+	}
+
+	return nil
+}
+
+// parseOutput parses the output with the textfsm template.
+func parseOutput(out, template string) ([]map[string]interface{}, error) {
+	return util.TextFsmParse(out, template)
+}
+
+// evaluate parses the output and checks the condition against the parsed records.
+// It returns the descriptions of the records violating the condition.
+func (c *fieldCondition) evaluate(out string) ([]string, error) {
+	records, err := parseOutput(out, c.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.evaluateRecords(records)
+}
+
+// evaluateRecords checks the condition against the parsed records.
+// It returns the descriptions of the records violating the condition.
+func (c *fieldCondition) evaluateRecords(records []map[string]interface{}) ([]string, error) {
+	var matched, unmatched []string
+
+	for i, rec := range records {
+		v, ok := rec[c.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: field %q not found in the parsed output",
+				errInvalidFieldCondition, c.Field)
+		}
+
+		desc := fmt.Sprintf("record %d: %s=%s", i, c.Field, fieldString(v))
+
+		if compareField(fieldString(v), c.Op, c.Value) {
+			matched = append(matched, desc)
+		} else {
+			unmatched = append(unmatched, desc)
+		}
+	}
+
+	switch c.Match {
+	case matchAny:
+		if len(matched) == 0 {
+			return append([]string{fmt.Sprintf("no record with %s %s %s",
+				c.Field, c.Op, c.Value)}, unmatched...), nil
+		}
+	case matchNone:
+		return matched, nil
+	default:
+		return unmatched, nil
+	}
+
+	return nil, nil
+}
+
+// fieldString returns the string representation of the parsed textfsm value,
+// list values are joined with commas.
+func fieldString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []string:
+		return strings.Join(val, ",")
+	case []interface{}:
+		s := make([]string, 0, len(val))
+		for _, e := range val {
+			s = append(s, fmt.Sprint(e))
+		}
+
+		return strings.Join(s, ",")
+	}
+
+	return fmt.Sprint(v)
+}
+
+// compareField compares the actual value with the expected one using the op operator.
+// Values are compared as numbers when both of them are numeric.
+func compareField(actual, op, expected string) bool {
+	switch op {
+	case "contains":
+		return strings.Contains(actual, expected)
+	case "matches":
+		return regexp.MustCompile(expected).MatchString(actual)
+	}
+
+	a, errA := strconv.ParseFloat(strings.TrimSpace(actual), 64)
+	e, errE := strconv.ParseFloat(strings.TrimSpace(expected), 64)
+
+	if errA == nil && errE == nil {
+		switch op {
+		case "==":
+			return a == e
+		case "!=":
+			return a != e
+		case ">":
+			return a > e
+		case ">=":
+			return a >= e
+		case "<":
+			return a < e
+		case "<=":
+			return a <= e
+		}
+	}
+
+	switch op {
+	case "==":
+		return actual == expected
+	case "!=":
+		return actual != expected
+	case ">":
+		return actual > expected
+	case ">=":
+		return actual >= expected
+	case "<":
+		return actual < expected
+	case "<=":
+		return actual <= expected
+	}
+
+	return false
+}
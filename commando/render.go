@@ -0,0 +1,87 @@
+package commando
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	renderedCommandsFile = "send-commands.txt"
+	renderedConfigsFile  = "send-configs.txt"
+	renderedIntendedFile = "intended-config.cfg"
+)
+
+// runRender writes the rendered commands and configs of every device
+// to the per-device directories of the app.renderDir directory without connecting to the devices.
+func (app *appCfg) runRender() error {
+	i := &inventory{}
+
+	if err := app.loadInventoryFromYAML(i); err != nil {
+		return err
+	}
+
+	for _, name := range sortedKeys(i.Devices) {
+		if err := app.renderDevice(name, i.Devices[name]); err != nil {
+			return fmt.Errorf("device %s: %w", name, err)
+		}
+	}
+
+	log.Infof("rendered configs have been saved to '%s' directory", app.renderDir)
+
+	return nil
+}
+
+// renderDevice writes the rendered files of the device, the device directory is recreated,
+// so that it doesn't keep the files of the previous renders.
+func (app *appCfg) renderDevice(name string, d *device) error {
+	if err := checkDirName(name); err != nil {
+		return err
+	}
+
+	files := map[string]string{}
+
+	if len(d.SendCommands) != 0 {
+		files[renderedCommandsFile] = strings.Join(d.SendCommands, "\n") + "\n"
+	}
+
+	if len(d.SendConfigs) != 0 {
+		files[renderedConfigsFile] = strings.Join(d.SendConfigs, "\n") + "\n"
+	}
+
+	for idx, op := range d.CfgOperations {
+		if op.Config != "" {
+			files[fmt.Sprintf("%s-%d.cfg", op.OperationType, idx+1)] = op.Config
+		}
+	}
+
+	if d.IntendedConfig != "" {
+		cfg, err := intendedConfig(name, d)
+		if err != nil {
+			return err
+		}
+
+		files[renderedIntendedFile] = cfg
+	}
+
+	dir := path.Join(app.renderDir, name)
+
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, filePermissions); err != nil {
+		return err
+	}
+
+	for f, s := range files {
+		if err := os.WriteFile(path.Join(dir, f), []byte(s), filePermissions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
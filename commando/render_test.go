@@ -0,0 +1,111 @@
+package commando
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRunRender(t *testing.T) {
+	intended := filepath.Join(t.TempDir(), "leaf.cfg")
+	if err := os.WriteFile(intended, []byte("hostname {{ .Name }}\nrouter bgp {{ .Vars.asn }}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, _ := writeInventories(t, map[string]string{
+		"inventory.yml": `credentials:
+  default:
+    username: admin
+vars:
+  asn: 65000
+devices:
+  leaf1:
+    platform: arista_eos
+    address: 192.0.2.1
+    vars:
+      asn: 65001
+    send-commands:
+      - show bgp summary | include {{ .Vars.asn }}
+    send-configs:
+      - hostname {{ .Name }}
+    cfg-operations:
+      - type: get-config
+      - type: load-config
+        config: |
+          router bgp {{ .Vars.asn }}
+    intended-config: ` + intended + `
+  leaf2:
+    platform: arista_eos
+    address: 192.0.2.2
+`,
+	}, "inventory.yml")
+
+	renderDir := filepath.Join(dir, "rendered")
+
+	// files of the previous renders must not be kept
+	stale := filepath.Join(renderDir, "leaf1", "load-config-1.cfg")
+	if err := os.MkdirAll(filepath.Dir(stale), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(stale, []byte("router bgp 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &appCfg{inventories: []string{filepath.Join(dir, "inventory.yml")}, renderDir: renderDir}
+	if err := app.runRender(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]string{
+		"leaf1": {
+			renderedCommandsFile: "show bgp summary | include 65001\n",
+			renderedConfigsFile:  "hostname leaf1\n",
+			"load-config-2.cfg":  "router bgp 65001\n",
+			renderedIntendedFile: "hostname leaf1\nrouter bgp 65001\n",
+		},
+		"leaf2": {},
+	}
+
+	got := map[string]map[string]string{}
+
+	for dev := range want {
+		entries, err := os.ReadDir(filepath.Join(renderDir, dev))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got[dev] = map[string]string{}
+
+		for _, e := range entries {
+			b, err := os.ReadFile(filepath.Join(renderDir, dev, e.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got[dev][e.Name()] = string(b)
+		}
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got rendered files %q, want %q", got, want)
+	}
+}
+
+func TestRenderDeviceInvalidName(t *testing.T) {
+	dir := t.TempDir()
+	app := &appCfg{renderDir: filepath.Join(dir, "rendered")}
+
+	for _, name := range []string{"..", "../rendered", "dc1/leaf1"} {
+		err := app.renderDevice(name, &device{SendCommands: []string{"show version"}})
+		if !errors.Is(err, errInvalidDeviceName) {
+			t.Errorf("device name %q: got error %v, want %v", name, err, errInvalidDeviceName)
+		}
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("files were written for the invalid device names: %v", entries)
+	}
+}
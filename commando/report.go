@@ -0,0 +1,282 @@
+package commando
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	consoleReport = "console"
+	jsonReport    = "json"
+	junitReport   = "junit"
+
+	exitCodeFailed  = 1 // checks could not be run on some devices
+	exitCodeFailing = 2 // checks ran and some of them failed
+)
+
+// checkResult is the outcome of a single check (drift, rule, test) run against a device.
+type checkResult struct {
+	Device  string   `json:"device"`
+	Check   string   `json:"check"`
+	Passed  bool     `json:"passed"`
+	Error   string   `json:"error,omitempty"`
+	Details []string `json:"details,omitempty"`
+}
+
+// checkReport holds the results of the named set of checks.
+type checkReport struct {
+	Name    string         `json:"name"`
+	Results []*checkResult `json:"results"`
+}
+
+// counts returns the number of the passed, failed and errored results.
+func (r *checkReport) counts() (passed, failed, errored int) {
+	for _, res := range r.Results {
+		switch {
+		case res.Error != "":
+			errored++
+		case res.Passed:
+			passed++
+		default:
+			failed++
+		}
+	}
+
+	return passed, failed, errored
+}
+
+// write writes the report in the f format to w.
+func (r *checkReport) write(w io.Writer, f string) error {
+	switch f {
+	case jsonReport:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(r)
+	case junitReport:
+		return writeJUnit(w, r)
+	case consoleReport, "":
+		r.writeConsole(w)
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", errInvalidReportFormat, f)
+}
+
+func (r *checkReport) writeConsole(w io.Writer) {
+	green := color.New(color.FgGreen)
+	red := color.New(color.FgRed)
+	bold := color.New(color.Bold)
+
+	dev := ""
+
+	for _, res := range r.Results {
+		if res.Device != dev {
+			dev = res.Device
+			bold.Fprintf(w, "\n%s\n", dev)
+		}
+
+		switch {
+		case res.Error != "":
+			red.Fprintf(w, "  ERROR %s: %s\n", res.Check, res.Error)
+		case res.Passed:
+			green.Fprintf(w, "  PASS  %s\n", res.Check)
+		default:
+			red.Fprintf(w, "  FAIL  %s\n", res.Check)
+		}
+
+		for _, d := range res.Details {
+			fmt.Fprintf(w, "        %s\n", d)
+		}
+	}
+
+	passed, failed, errored := r.counts()
+	fmt.Fprintf(w, "\n%s: %d passed, %d failed, %d errored\n", r.Name, passed, failed, errored)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name          `xml:"testsuites"`
+	Suites  []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes the reports as JUnit test suites with a test case per device and check.
+func writeJUnit(w io.Writer, reps ...*checkReport) error {
+	suites := &junitTestSuites{}
+	for _, r := range reps {
+		suites.Suites = append(suites.Suites, r.junitSuite())
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+func (r *checkReport) junitSuite() *junitTestSuite {
+	_, failed, errored := r.counts()
+
+	ts := &junitTestSuite{
+		Name:     r.Name,
+		Tests:    len(r.Results),
+		Failures: failed,
+		Errors:   errored,
+	}
+
+	for _, res := range r.Results {
+		tc := junitTestCase{Name: res.Check, ClassName: res.Device}
+
+		switch {
+		case res.Error != "":
+			tc.Error = &junitMessage{Message: res.Error}
+		case !res.Passed:
+			tc.Failure = &junitMessage{
+				Message: fmt.Sprintf("%s failed", res.Check),
+				Text:    strings.Join(res.Details, "\n"),
+			}
+		}
+
+		ts.Cases = append(ts.Cases, tc)
+	}
+
+	return ts
+}
+
+// reportExt returns the file extension for the f report format.
+func reportExt(f string) string {
+	switch f {
+	case consoleReport, "":
+		return "txt"
+	case junitReport:
+		return "xml"
+	}
+
+	return f
+}
+
+// writeReports saves and prints the reports.
+// The returned error carries the exit code reflecting the results.
+func (app *appCfg) writeReports(reps ...*checkReport) error {
+	if err := app.saveReports(reps...); err != nil {
+		return err
+	}
+
+	return app.printReports(reps...)
+}
+
+// saveReports saves the reports in the configured format to the output directory,
+// if the output is file based.
+func (app *appCfg) saveReports(reps ...*checkReport) error {
+	if app.outDir == "" || len(reps) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(app.outDir, filePermissions); err != nil {
+		return err
+	}
+
+	noColor := color.NoColor
+	color.NoColor = true
+
+	defer func() { color.NoColor = noColor }()
+
+	for _, r := range reps {
+		fName := path.Join(app.outDir, fmt.Sprintf("%s-report.%s",
+			sanitizeFileName(strings.ToLower(r.Name)), reportExt(app.reportFormat)))
+
+		f, err := os.Create(fName)
+		if err != nil {
+			return err
+		}
+
+		err = r.write(f, app.reportFormat)
+		f.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printReports prints the reports in the configured format to stdout.
+// The returned error carries the exit code reflecting the results.
+func (app *appCfg) printReports(reps ...*checkReport) error {
+	var failed, errored int
+
+	// junit and json reports are combined in a single document
+	switch {
+	case len(reps) == 0:
+	case app.reportFormat == junitReport:
+		if err := writeJUnit(os.Stdout, reps...); err != nil {
+			return err
+		}
+	case app.reportFormat == jsonReport:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(reps); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range reps {
+		if app.reportFormat != junitReport && app.reportFormat != jsonReport {
+			if err := r.write(os.Stdout, app.reportFormat); err != nil {
+				return err
+			}
+		}
+
+		_, f, e := r.counts()
+		failed += f
+		errored += e
+	}
+
+	switch {
+	case errored != 0:
+		return cli.Exit(fmt.Sprintf("%d check(s) could not be run", errored), exitCodeFailed)
+	case failed != 0:
+		return cli.Exit(fmt.Sprintf("%d check(s) failed", failed), exitCodeFailing)
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package commando
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+)
+
+func testReport() *checkReport {
+	return &checkReport{
+		Name: "compliance",
+		Results: []*checkResult{
+			{Device: "r1", Check: "ntp", Passed: true},
+			{Device: "r1", Check: "snmp", Details: []string{"offending line: snmp-server community public RO"}},
+			{Device: "r2", Check: "ntp", Error: "no output collected for show run"},
+		},
+	}
+}
+
+func TestCheckReportWrite(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+
+	defer func() { color.NoColor = noColor }()
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: consoleReport,
+			want: `
+r1
+  PASS  ntp
+  FAIL  snmp
+        offending line: snmp-server community public RO
+
+r2
+  ERROR ntp: no output collected for show run
+
+compliance: 1 passed, 1 failed, 1 errored
+`,
+		},
+		{
+			format: junitReport,
+			want: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="compliance" tests="3" failures="1" errors="1">
+    <testcase name="ntp" classname="r1"></testcase>
+    <testcase name="snmp" classname="r1">
+      <failure message="snmp failed">offending line: snmp-server community public RO</failure>
+    </testcase>
+    <testcase name="ntp" classname="r2">
+      <error message="no output collected for show run"></error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := testReport().write(&b, tt.format); err != nil {
+				t.Fatal(err)
+			}
+
+			if b.String() != tt.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", b.String(), tt.want)
+			}
+		})
+	}
+
+	t.Run(jsonReport, func(t *testing.T) {
+		var b bytes.Buffer
+		if err := testReport().write(&b, jsonReport); err != nil {
+			t.Fatal(err)
+		}
+
+		got := &checkReport{}
+		if err := json.Unmarshal(b.Bytes(), got); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, testReport()) {
+			t.Fatalf("got %s", b.String())
+		}
+	})
+
+	if err := testReport().write(&bytes.Buffer{}, "yaml"); !errors.Is(err, errInvalidReportFormat) {
+		t.Fatalf("got error %v, want %v", err, errInvalidReportFormat)
+	}
+}
+
+func TestPrintReportsExitCodes(t *testing.T) {
+	// the reports printed to stdout are not checked here
+	discardStdout(t)
+
+	passed := &checkReport{Name: "drift", Results: []*checkResult{{Device: "r1", Check: "drift", Passed: true}}}
+	failed := &checkReport{Name: "drift", Results: []*checkResult{{Device: "r1", Check: "drift"}}}
+
+	tests := []struct {
+		name     string
+		reports  []*checkReport
+		wantCode int
+	}{
+		{"no reports", nil, 0},
+		{"passed", []*checkReport{passed}, 0},
+		{"failed", []*checkReport{passed, failed}, exitCodeFailing},
+		{"errored", []*checkReport{failed, testReport()}, exitCodeFailed},
+	}
+
+	for _, tt := range tests {
+		for _, format := range []string{consoleReport, jsonReport, junitReport} {
+			t.Run(tt.name+" "+format, func(t *testing.T) {
+				app := &appCfg{reportFormat: format}
+
+				err := app.printReports(tt.reports...)
+				if tt.wantCode == 0 {
+					if err != nil {
+						t.Fatalf("got error %v", err)
+					}
+
+					return
+				}
+
+				var exitErr cli.ExitCoder
+				if !errors.As(err, &exitErr) || exitErr.ExitCode() != tt.wantCode {
+					t.Fatalf("got error %v, want the exit code %d", err, tt.wantCode)
+				}
+			})
+		}
+	}
+}
+
+func TestSaveReports(t *testing.T) {
+	dir := t.TempDir()
+	app := &appCfg{outDir: filepath.Join(dir, "outputs"), reportFormat: jsonReport}
+
+	if err := app.saveReports(testReport(), &checkReport{Name: "Drift"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{"compliance-report.json", "drift-report.json"} {
+		if _, err := os.Stat(filepath.Join(app.outDir, f)); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -22,26 +22,44 @@ type responseWriter interface {
 	WriteResponse(r []interface{}, name string) error
 }
 
+// finalizer is implemented by the response writers that need to act
+// once all the responses of a run have been written.
+type finalizer interface {
+	Finalize() error
+}
+
 func (app *appCfg) newResponseWriter(f string) responseWriter {
 	switch f {
 	case fileOutput:
-		parentDir := "outputs"
-		if app.timestamp {
-			parentDir = parentDir + "_" + time.Now().Format(time.RFC3339)
-		}
+		parentDir := app.fileOutputDir()
 
 		app.outDir = parentDir
 
 		return &fileWriter{
-			parentDir,
+			dir:     parentDir,
+			norm:    app.normalizer,
+			keepRaw: app.keepRaw,
 		}
 	case stdoutOutput:
 		return &consoleWriter{}
+	case gitOutput:
+		app.outDir = app.gitRepo
+
+		return app.newGitWriter()
 	}
 
 	return nil
 }
 
+// fileOutputDir returns the name of the output directory for the file output.
+func (app *appCfg) fileOutputDir() string {
+	if app.timestamp {
+		return outputDirPrefix + "_" + time.Now().Format(time.RFC3339)
+	}
+
+	return outputDirPrefix
+}
+
 // consoleWriter writes the scrapli responses to the console.
 type consoleWriter struct{}
 
@@ -107,42 +125,75 @@ func (w *consoleWriter) WriteResponse(r []interface{}, name string) error {
 
 // fileWriter writes the scrapli responses to the files on disk.
 type fileWriter struct {
-	dir string // output dir name
+	dir     string      // output dir name
+	norm    *normalizer // normalisation rules applied before saving
+	keepRaw bool        // save raw outputs in addition to the normalised ones
 }
 
 func (w *fileWriter) WriteResponse(r []interface{}, name string) error {
+	return w.writeOutputs(name, responseOutputs(r))
+}
+
+// writeOutputs saves the outputs of the device keyed by the file names.
+func (w *fileWriter) writeOutputs(name string, outs map[string]string) error {
 	outDir := path.Join(w.dir, name)
 	if err := os.MkdirAll(outDir, filePermissions); err != nil {
 		return err
 	}
 
+	if w.keepRaw && w.norm != nil {
+		rawDir := path.Join(outDir, rawOutputDir)
+		if err := os.MkdirAll(rawDir, filePermissions); err != nil {
+			return err
+		}
+
+		for f, out := range outs {
+			if err := os.WriteFile(path.Join(rawDir, f), []byte(out), filePermissions); err != nil {
+				return err
+			}
+		}
+	}
+
+	for f, out := range w.norm.applyDevice(name, outs) {
+		if err := os.WriteFile(path.Join(outDir, f), []byte(out), filePermissions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// responseOutputs returns the textual outputs of the scrapli responses
+// keyed by the file name the output is saved under.
+func responseOutputs(r []interface{}) map[string]string {
+	outs := map[string]string{}
+
 	for _, mr := range r {
 		switch respObj := mr.(type) {
 		case *response.MultiResponse:
 			for _, resp := range respObj.Responses {
 				c := sanitizeFileName(resp.Input) // replace unsafe chars from a file name
 
-				rb := []byte(resp.Result)
-				if err := os.WriteFile(path.Join(outDir, c), rb, filePermissions); err != nil {
-					return err
-				}
+				outs[c] = resp.Result
 			}
 		case *cfgresponse.Response:
-			rb := []byte(respObj.Result)
-			if err := os.WriteFile(path.Join(outDir, respObj.Op), rb, filePermissions); err != nil {
-				return err
-			}
+			outs[respObj.Op] = respObj.Result
 		case *cfgresponse.DiffResponse:
-			rb := []byte(
-				fmt.Sprintf("Device Diff:\n%s\n\nSide By Side Diff:\n%s\n\nUnified Diff:\n%s",
-					respObj.DeviceDiff, respObj.SideBySideDiff(), respObj.UnifiedDiff()),
-			)
-			if err := os.WriteFile(path.Join(outDir, respObj.Op), rb, filePermissions); err != nil {
-				return err
-			}
+			outs[respObj.Op] = fmt.Sprintf("Device Diff:\n%s\n\nSide By Side Diff:\n%s\n\nUnified Diff:\n%s",
+				respObj.DeviceDiff, respObj.SideBySideDiff(), respObj.UnifiedDiff())
 		}
 	}
 
+	return outs
+}
+
+// checkDirName ensures that the device name is a single clean path element,
+// so that the device directory doesn't point outside of the output directory.
+func checkDirName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || path.Clean(name) != name {
+		return fmt.Errorf("%w: %q", errInvalidDeviceName, name)
+	}
+
 	return nil
 }
 
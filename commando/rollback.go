@@ -0,0 +1,152 @@
+package commando
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scrapli/scrapligocfg"
+	cfgresponse "github.com/scrapli/scrapligocfg/response"
+	cfgutil "github.com/scrapli/scrapligocfg/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const rollbackOp = "Rollback"
+
+// rollbackOnFailure runs the post-checks of the device after the commit and restores
+// the saved config if any of them fails, loading it with the opts of the rolled back load-config.
+// When the config is rolled back, it returns the response recording the rollback
+// along with the errConfigRolledBack error, so that the device is reported as failed.
+func (app *appCfg) rollbackOnFailure(
+	name string,
+	d *device,
+	c *scrapligocfg.Cfg,
+	op *cfgOperation,
+	saved string,
+	opts ...cfgutil.Option,
+) (*cfgresponse.Response, error) {
+	time.Sleep(op.Settle)
+
+	failures := app.postCheckFailures(name, d, c)
+	if len(failures) == 0 {
+		return nil, nil
+	}
+
+	log.Warnf("post-checks failed for device %s, rolling back the config", name)
+
+	r, err := restoreConfig(name, c, saved, "failed post-checks:\n"+strings.Join(failures, "\n"), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, fmt.Errorf("%w: failed post-checks: %s", errConfigRolledBack, strings.Join(failures, "; "))
+}
+
+// rolledBackResponses returns the responses along with the err if it reports the rollback
+// of the config, so that the responses recording the rollback are kept. Otherwise only the err is returned.
+func rolledBackResponses(responses []interface{}, err error) ([]interface{}, error) {
+	if !errors.Is(err, errConfigRolledBack) {
+		return nil, err
+	}
+
+	return responses, err
+}
+
+// restoreConfig replaces the running config with the saved one and commits it.
+// The saved config is normalised first, so that the headers of the get-config output aren't loaded.
+// It returns the response recording the rollback and its reason.
+func restoreConfig(
+	name string,
+	c *scrapligocfg.Cfg,
+	saved, reason string,
+	opts ...cfgutil.Option,
+) (*cfgresponse.Response, error) {
+	r := cfgresponse.NewResponse(rollbackOp, c.Conn.Transport.GetHost())
+
+	// native rollback is not exposed by scrapligocfg, so the saved config replaces the running one
+	if _, err := c.LoadConfig(c.Impl.NormalizeConfig(saved), true, opts...); err != nil {
+		log.Errorf("rollback load-config failed for device %s; error: %+v\n", name, err)
+
+		return nil, err
+	}
+
+	cr, err := c.CommitConfig()
+	if err != nil {
+		log.Errorf("rollback commit-config failed for device %s; error: %+v\n", name, err)
+
+		return nil, err
+	}
+
+	r.Record(cr.ScrapliResponses, "config rolled back to the one saved before the commit, "+reason)
+
+	return r, nil
+}
+
+// postCheckFailures runs the check commands of the device and evaluates the state tests
+// of these commands against their outputs. It returns the descriptions of the failures.
+func (app *appCfg) postCheckFailures(name string, d *device, c *scrapligocfg.Cfg) []string {
+	outs, err := runChecks(d, c.Conn)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to run the checks: %v", err)}
+	}
+
+	return app.checkFailures(name, d, outs)
+}
+
+// checkFailures evaluates the post-check tests of the device against the outs outputs of its checks
+// and returns the descriptions of the failures.
+func (app *appCfg) checkFailures(name string, d *device, outs map[string]string) []string {
+	var failures []string
+
+	rep := runTests(app.postCheckTests(d), map[string]*device{name: d}, outputSet{name: outs})
+	for _, res := range rep.Results {
+		switch {
+		case res.Error != "":
+			failures = append(failures, fmt.Sprintf("%s: %s", res.Check, res.Error))
+		case !res.Passed:
+			failures = append(failures, fmt.Sprintf("%s: %s", res.Check, strings.Join(res.Details, "; ")))
+		}
+	}
+
+	return failures
+}
+
+// postCheckTests returns the state tests of the check commands of the device,
+// only these tests are post-checks.
+func (app *appCfg) postCheckTests(d *device) []*stateTest {
+	var tests []*stateTest
+
+	for _, t := range app.tests {
+		for _, c := range d.Checks {
+			if sanitizeFileName(c) == sanitizeFileName(t.Command) {
+				tests = append(tests, t)
+
+				break
+			}
+		}
+	}
+
+	return tests
+}
+
+// rolledBackError logs the devices which configs were rolled back during the run
+// and returns the exit error if there are any.
+func (app *appCfg) rolledBackError() error {
+	var rolledBack int
+
+	for _, name := range sortedKeys(app.failures) {
+		if err := app.failures[name]; errors.Is(err, errConfigRolledBack) {
+			rolledBack++
+
+			log.Errorf("change failed for device %s; error: %+v\n", name, err)
+		}
+	}
+
+	if rolledBack != 0 {
+		return cli.Exit(fmt.Sprintf("config rolled back on %d device(s)", rolledBack), exitCodeFailed)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package commando
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cfgresponse "github.com/scrapli/scrapligocfg/response"
+	"github.com/urfave/cli/v2"
+)
+
+const bgpPeersTemplate = `Value NEIGHBOR (\S+)
+Value STATE (\S+)
+
+Start
+  ^${NEIGHBOR}\s+${STATE}\s*$$ -> Record
+`
+
+func TestCheckFailures(t *testing.T) {
+	tpl := filepath.Join(t.TempDir(), "bgp.textfsm")
+	if err := os.WriteFile(tpl, []byte(bgpPeersTemplate), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &appCfg{tests: []*stateTest{{
+		Name:     "bgp-peers",
+		Command:  "show bgp summary",
+		Template: tpl,
+		Assert:   []*assertion{{Count: &countCheck{Op: "==", Value: "2"}}},
+	}}}
+
+	tests := []struct {
+		name   string
+		checks []string
+		outs   map[string]string
+		want   []string
+	}{
+		{
+			name:   "passing",
+			checks: []string{"show bgp summary"},
+			outs:   map[string]string{"show-bgp-summary": "10.0.0.1 Established\n10.0.0.3 Established\n"},
+		},
+		{
+			name:   "failing",
+			checks: []string{"show bgp summary"},
+			outs:   map[string]string{"show-bgp-summary": "10.0.0.1 Established\n"},
+			want:   []string{"bgp-peers: expected count == 2, got 1"},
+		},
+		{
+			name:   "no output",
+			checks: []string{"show bgp summary"},
+			outs:   map[string]string{},
+			want:   []string{"bgp-peers: no output collected for show bgp summary"},
+		},
+		{
+			name:   "not a check of the device",
+			checks: []string{"show version"},
+			outs:   map[string]string{"show-version": "4.28"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &device{Checks: tt.checks}
+
+			if got := app.checkFailures("r1", d, tt.outs); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRolledBackResponses(t *testing.T) {
+	r := cfgresponse.NewResponse(rollbackOp, "192.0.2.1")
+
+	tests := []struct {
+		name string
+		err  error
+		want []interface{}
+	}{
+		{"rolled back", fmt.Errorf("%w: failed post-checks", errConfigRolledBack), []interface{}{r}},
+		{"other error", errors.New("commit failed"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rolledBackResponses([]interface{}{r}, tt.err)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got responses %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRolledBackDevicesFailTheRun(t *testing.T) {
+	app := &appCfg{
+		outputs: outputSet{
+			"r1": {"show-version": "4.28"},
+			"r2": {rollbackOp: "config rolled back"},
+		},
+		failures: map[string]error{
+			"r2": fmt.Errorf("%w: failed post-checks: bgp-peers: expected count == 2, got 1", errConfigRolledBack),
+			"r3": errors.New("connection refused"),
+		},
+	}
+
+	m := app.newRunManifest(map[string]*device{"r1": {}, "r2": {}, "r3": {}})
+
+	want := []*manifestDevice{
+		{Name: "r1", Status: deviceStatusOK},
+		{
+			Name:   "r2",
+			Status: deviceStatusFailed,
+			Error:  "config rolled back: failed post-checks: bgp-peers: expected count == 2, got 1",
+		},
+		{Name: "r3", Status: deviceStatusFailed, Error: "connection refused"},
+	}
+
+	if !reflect.DeepEqual(m.Devices, want) {
+		for _, d := range m.Devices {
+			t.Errorf("%+v", *d)
+		}
+
+		t.Fatal("manifest devices differ")
+	}
+
+	var exitErr cli.ExitCoder
+	if err := app.rolledBackError(); !errors.As(err, &exitErr) || exitErr.ExitCode() != exitCodeFailed {
+		t.Fatalf("got error %v, want the failed exit code %d", err, exitCodeFailed)
+	}
+
+	app.failures = map[string]error{"r3": errors.New("connection refused")}
+
+	if err := app.rolledBackError(); err != nil {
+		t.Fatalf("got error %v without the rolled back devices", err)
+	}
+}
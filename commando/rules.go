@@ -0,0 +1,301 @@
+package commando
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/scrapli/scrapligocfg"
+	"gopkg.in/yaml.v2"
+)
+
+const complianceReportName = "compliance"
+
+// deviceScope selects the devices a rule or a test applies to.
+type deviceScope struct {
+	// platforms the check applies to. Applies to all platforms when empty.
+	Platforms []string `yaml:"platforms,omitempty"`
+	// tags the check applies to. A device must have at least one of the tags.
+	// Applies to all devices when empty.
+	Tags []string `yaml:"tags,omitempty"`
+}
+
+func (s *deviceScope) applies(d *device) bool {
+	if len(s.Platforms) != 0 && !contains(s.Platforms, d.Platform) {
+		return false
+	}
+
+	if len(s.Tags) == 0 {
+		return true
+	}
+
+	for _, t := range s.Tags {
+		if contains(d.Tags, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type rulesFile struct {
+	Rules []*rule `yaml:"rules,omitempty"`
+}
+
+// rule is a compliance check of the collected outputs.
+type rule struct {
+	Name        string `yaml:"name,omitempty"`
+	deviceScope `yaml:",inline"`
+	// commands which outputs the rule is applied to.
+	Commands []string `yaml:"commands,omitempty"`
+	// apply the rule to the config retrieved with the get-config cfg operation.
+	Config bool `yaml:"config,omitempty"`
+	// the output must have a line matching this pattern.
+	MustContain string `yaml:"must-contain,omitempty"`
+	// the output must not have lines matching this pattern.
+	MustNotContain string `yaml:"must-not-contain,omitempty"`
+	// sections of the output that must contain lines.
+	Section *sectionCheck `yaml:"section,omitempty"`
+	// condition on the fields parsed from the output.
+	Field *fieldCondition `yaml:"field,omitempty"`
+
+	mustContain    *regexp.Regexp
+	mustNotContain *regexp.Regexp
+}
+
+// sectionCheck checks that every section of the output which header matches
+// the Match pattern contains lines matching the Contains patterns.
+// A section consists of the header line and the following lines indented deeper than the header.
+type sectionCheck struct {
+	Match    string   `yaml:"match,omitempty"`
+	Contains []string `yaml:"contains,omitempty"`
+
+	match    *regexp.Regexp
+	contains []*regexp.Regexp
+}
+
+// loadRules loads and validates the compliance rules from the f file.
+func loadRules(f string) ([]*rule, error) {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	rf := &rulesFile{}
+	if err := yaml.UnmarshalStrict(b, rf); err != nil {
+		return nil, err
+	}
+
+	for idx, r := range rf.Rules {
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", idx, r.Name, err)
+		}
+	}
+
+	return rf.Rules, nil
+}
+
+func (r *rule) compile() error {
+	var err error
+
+	if r.Name == "" {
+		return fmt.Errorf("%w: name must be set", errInvalidRule)
+	}
+
+	if len(r.Commands) == 0 && !r.Config {
+		return fmt.Errorf("%w: commands or config must be set", errInvalidRule)
+	}
+
+	if r.MustContain == "" && r.MustNotContain == "" && r.Section == nil && r.Field == nil {
+		return fmt.Errorf("%w: no checks defined", errInvalidRule)
+	}
+
+	if r.MustContain != "" {
+		if r.mustContain, err = regexp.Compile(r.MustContain); err != nil {
+			return err
+		}
+	}
+
+	if r.MustNotContain != "" {
+		if r.mustNotContain, err = regexp.Compile(r.MustNotContain); err != nil {
+			return err
+		}
+	}
+
+	if r.Section != nil {
+		if r.Section.match, err = regexp.Compile(r.Section.Match); err != nil {
+			return err
+		}
+
+		for _, c := range r.Section.Contains {
+			re, err := regexp.Compile(c)
+			if err != nil {
+				return err
+			}
+
+			r.Section.contains = append(r.Section.contains, re)
+		}
+	}
+
+	if r.Field != nil {
+		return r.Field.validate()
+	}
+
+	return nil
+}
+
+// targets returns the output file names the rule applies to.
+func (r *rule) targets() []string {
+	t := make([]string, 0, len(r.Commands)+1)
+
+	for _, c := range r.Commands {
+		t = append(t, sanitizeFileName(c))
+	}
+
+	if r.Config {
+		t = append(t, scrapligocfg.GetConfig)
+	}
+
+	return t
+}
+
+// evaluate applies the rule to the outputs of the dev device.
+func (r *rule) evaluate(dev string, outs map[string]string) *checkResult {
+	res := &checkResult{Device: dev, Check: r.Name}
+
+	for _, t := range r.targets() {
+		out, ok := outs[t]
+		if !ok {
+			res.Error = fmt.Sprintf("no output collected for %s", t)
+
+			return res
+		}
+
+		details, err := r.check(out)
+		if err != nil {
+			res.Error = fmt.Sprintf("%s: %v", t, err)
+
+			return res
+		}
+
+		for _, d := range details {
+			res.Details = append(res.Details, t+": "+d)
+		}
+	}
+
+	res.Passed = len(res.Details) == 0
+
+	return res
+}
+
+// check returns the violations of the rule found in the output.
+func (r *rule) check(out string) ([]string, error) {
+	var violations []string
+
+	lines := strings.Split(out, "\n")
+
+	if r.mustContain != nil && !anyLineMatches(r.mustContain, lines) {
+		violations = append(violations, fmt.Sprintf("no line matching %q", r.MustContain))
+	}
+
+	if r.mustNotContain != nil {
+		for _, l := range lines {
+			if r.mustNotContain.MatchString(l) {
+				violations = append(violations, "offending line: "+strings.TrimSpace(l))
+			}
+		}
+	}
+
+	if r.Section != nil {
+		violations = append(violations, r.Section.check(lines)...)
+	}
+
+	if r.Field != nil {
+		v, err := r.Field.evaluate(out)
+		if err != nil {
+			return nil, err
+		}
+
+		violations = append(violations, v...)
+	}
+
+	return violations, nil
+}
+
+func (s *sectionCheck) check(lines []string) []string {
+	var violations []string
+
+	found := false
+
+	for i := 0; i < len(lines); i++ {
+		if !s.match.MatchString(lines[i]) {
+			continue
+		}
+
+		found = true
+		header := lines[i]
+		indent := indentation(header)
+
+		var body []string
+
+		for i+1 < len(lines) && (strings.TrimSpace(lines[i+1]) == "" || indentation(lines[i+1]) > indent) {
+			i++
+			body = append(body, lines[i])
+		}
+
+		for idx, c := range s.contains {
+			if !anyLineMatches(c, body) {
+				violations = append(violations, fmt.Sprintf("section %q has no line matching %q",
+					strings.TrimSpace(header), s.Contains[idx]))
+			}
+		}
+	}
+
+	if !found {
+		violations = append(violations, fmt.Sprintf("no section matching %q", s.Match))
+	}
+
+	return violations
+}
+
+// checkCompliance evaluates the rules against the outputs of the devices.
+func checkCompliance(rules []*rule, devs map[string]*device, outs outputSet) *checkReport {
+	rep := &checkReport{Name: complianceReportName}
+
+	for _, name := range sortedKeys(devs) {
+		for _, r := range rules {
+			if !r.applies(devs[name]) {
+				continue
+			}
+
+			rep.Results = append(rep.Results, r.evaluate(name, outs[name]))
+		}
+	}
+
+	return rep
+}
+
+func anyLineMatches(re *regexp.Regexp, lines []string) bool {
+	for _, l := range lines {
+		if re.MatchString(l) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func indentation(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " \t"))
+}
+
+func contains(s []string, e string) bool {
+	for _, v := range s {
+		if v == e {
+			return true
+		}
+	}
+
+	return false
+}
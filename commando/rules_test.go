@@ -0,0 +1,189 @@
+package commando
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+const ruleTestConfig = `hostname r1
+ntp server 10.0.0.1
+snmp-server community public RO
+interface Ethernet1
+   mtu 9000
+interface Ethernet2
+   description uplink
+`
+
+func TestRuleCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *rule
+		want []string
+	}{
+		{
+			name: "must-contain passes",
+			rule: &rule{MustContain: `^ntp server 10\.0\.0\.1`},
+		},
+		{
+			name: "must-contain fails",
+			rule: &rule{MustContain: `^ntp server 10\.0\.0\.2`},
+			want: []string{`no line matching "^ntp server 10\\.0\\.0\\.2"`},
+		},
+		{
+			name: "must-not-contain reports the offending lines",
+			rule: &rule{MustNotContain: `community public`},
+			want: []string{"offending line: snmp-server community public RO"},
+		},
+		{
+			name: "every matching section must contain the lines",
+			rule: &rule{Section: &sectionCheck{Match: `^interface Ethernet`, Contains: []string{`mtu 9000`}}},
+			want: []string{`section "interface Ethernet2" has no line matching "mtu 9000"`},
+		},
+		{
+			name: "no matching section",
+			rule: &rule{Section: &sectionCheck{Match: `^interface Loopback`, Contains: []string{`ip address`}}},
+			want: []string{`no section matching "^interface Loopback"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Name, tt.rule.Config = "test", true
+
+			if err := tt.rule.compile(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := tt.rule.check(ruleTestConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *rule
+	}{
+		{"no name", &rule{Config: true, MustContain: "x"}},
+		{"no target", &rule{Name: "r", MustContain: "x"}},
+		{"no checks", &rule{Name: "r", Config: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.compile(); !errors.Is(err, errInvalidRule) {
+				t.Fatalf("got error %v, want %v", err, errInvalidRule)
+			}
+		})
+	}
+}
+
+func TestCheckCompliance(t *testing.T) {
+	rules := []*rule{
+		{Name: "ntp", Commands: []string{"show run"}, MustContain: "^ntp server"},
+		{Name: "eos-only", Commands: []string{"show run"}, MustContain: "^hostname",
+			deviceScope: deviceScope{Platforms: []string{"arista_eos"}}},
+		{Name: "core-only", Config: true, MustContain: "^hostname",
+			deviceScope: deviceScope{Tags: []string{"core"}}},
+	}
+
+	for _, r := range rules {
+		if err := r.compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	devs := map[string]*device{
+		"r1": {Platform: "arista_eos", Tags: []string{"core"}},
+		"r2": {Platform: "cisco_iosxe"},
+	}
+
+	outs := outputSet{
+		"r1": {"show-run": "hostname r1\nntp server 10.0.0.1"},
+		"r2": {"show-run": "hostname r2"},
+	}
+
+	type result struct {
+		Device, Check string
+		Passed        bool
+		Error         string
+	}
+
+	var got []result
+	for _, r := range checkCompliance(rules, devs, outs).Results {
+		got = append(got, result{r.Device, r.Check, r.Passed, r.Error})
+	}
+
+	want := []result{
+		{"r1", "ntp", true, ""},
+		{"r1", "eos-only", true, ""},
+		{"r1", "core-only", false, "no output collected for GetConfig"},
+		{"r2", "ntp", false, ""},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+// TestAnalyzeComplianceOnRawOutputs checks the normalisation rules can't hide the violations.
+func TestAnalyzeComplianceOnRawOutputs(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"inventory.yml": `credentials:
+  default:
+    username: admin
+devices:
+  r1:
+    platform: arista_eos
+    address: 192.0.2.1
+normalize:
+  - drop-lines: ['^uptime']
+`,
+		"rules.yml": `rules:
+  - name: no-uptime
+    commands: [show version]
+    must-not-contain: '^uptime'
+`,
+		"outputs/r1/show-version":     "version 4.28\n",
+		"outputs/r1/raw/show-version": "version 4.28\nuptime 9\n",
+	}
+
+	for name, data := range files {
+		p := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(p, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := &appCfg{
+		inventories:  []string{filepath.Join(dir, "inventory.yml")},
+		inventorySet: true,
+		analyzeFrom:  filepath.Join(dir, "outputs"),
+		rulesFile:    filepath.Join(dir, "rules.yml"),
+		reportFormat: consoleReport,
+	}
+
+	var exitErr cli.ExitCoder
+	if err := app.runAnalyze(); !errors.As(err, &exitErr) || exitErr.ExitCode() != exitCodeFailing {
+		t.Fatalf("got error %v, want the failed checks exit code %d", err, exitCodeFailing)
+	}
+}
@@ -0,0 +1,256 @@
+package commando
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	schemaDraft     = "http://json-schema.org/draft-07/schema#"
+	schemaDefsRef   = "#/definitions/"
+	durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+)
+
+// jsonSchema is the subset of the JSON Schema describing the inventory.
+type jsonSchema struct {
+	Schema      string   `json:"$schema,omitempty"`
+	Ref         string   `json:"$ref,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	// properties of the object with the fields known to the inventory types.
+	Properties map[string]*jsonSchema `json:"properties,omitempty"`
+	// false for the objects without the unknown fields, the schema of the values for the maps.
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	OneOf                []*jsonSchema          `json:"oneOf,omitempty"`
+	Definitions          map[string]*jsonSchema `json:"definitions,omitempty"`
+}
+
+// typeDescriptions are the descriptions of the inventory types by their names.
+var typeDescriptions = map[string]string{ //nolint:gochecknoglobals
+	"inventory":    "cmdo inventory of the devices to run the commands and cfg operations against",
+	"credentials":  "credentials used to authenticate with the devices",
+	"transports":   "transport options used to connect to the devices",
+	"device":       "device to run the commands and cfg operations against",
+	"cfgOperation": "config management operation run with scrapligocfg",
+	"group":        "named set of devices sharing the variables and the intended config",
+	"normalizeRule": "rule removing or replacing the volatile parts of the outputs " +
+		"before they are saved and compared",
+	"replaceRule": "replace operation of the normalisation rule",
+}
+
+// fieldDescriptions are the descriptions of the inventory types fields by the type and yaml field names.
+var fieldDescriptions = map[string]string{ //nolint:gochecknoglobals
+	"inventory.include": "paths or glob patterns of the inventory files to include, " +
+		"relative to this file",
+	"inventory.credentials": "credentials by their names, the default credentials are used " +
+		"by the devices which don't reference any",
+	"inventory.transports": "transport options by their names, the default transport is used " +
+		"by the devices which don't reference any",
+	"inventory.devices":   "devices by their names",
+	"inventory.normalize": "normalisation rules applied to the outputs in order",
+	"inventory.groups":    "groups by their names",
+	"inventory.vars":      "variables available to the templates of all devices",
+
+	"credentials.username": "username, may reference a secret with ${env:VAR}, file:/path or exec:cmd",
+	"credentials.password": "password, may reference a secret with ${env:VAR}, file:/path or exec:cmd, " +
+		"or be set to prompt to ask for it",
+	"credentials.secondary-password": "secondary (enable) password, may reference a secret " +
+		"with ${env:VAR}, file:/path or exec:cmd, or be set to prompt to ask for it",
+	"credentials.private-key": "path to the private key",
+
+	"transports.port":            "port to connect to, 22 by default",
+	"transports.strict-key":      "check the host keys",
+	"transports.ssh-config-file": "path to the ssh config file",
+	"transports.transport-type":  "transport type, standard by default",
+
+	"device.platform":      "platform of the device",
+	"device.address":       "address of the device",
+	"device.credentials":   "name of the credentials, or a list of names tried in order on authentication failures",
+	"device.transport":     "name of the transport options",
+	"device.send-commands": "commands to send, rendered as templates",
+	"device.send-commands-from-file": "path to the file with the commands to send, " +
+		"rendered as a template and sent before send-commands",
+	"device.send-configs": "configs to send, rendered as templates",
+	"device.send-configs-from-file": "path to the file with the configs to send, " +
+		"rendered as a template and sent before send-configs",
+	"device.cfg-operations": "cfg operations run in order before the configs and commands are sent",
+	"device.intended-config": "path to the intended config template the running config is compared with, " +
+		"overriding the groups one",
+	"device.tags":   "tags selecting the compliance rules and state tests applied to the device",
+	"device.checks": "check commands run before and after the change",
+	"device.groups": "groups the device belongs to, their vars are applied in the listed order",
+	"device.vars":   "variables of the device, overriding the inventory and groups vars",
+
+	"cfgOperation.type":             "type of the operation",
+	"cfgOperation.source":           "config source of get-config, running by default",
+	"cfgOperation.config":           "candidate config of load-config and validate, rendered as a template",
+	"cfgOperation.config-from-file": "path to the candidate config file, rendered as a template",
+	"cfgOperation.replace":          "replace the whole config with the candidate instead of merging it",
+	"cfgOperation.diff":             "show the diff of the candidate and the running config",
+	"cfgOperation.commit":           "commit the loaded candidate",
+	"cfgOperation.rollback-on-failure": "restore the config saved before the commit " +
+		"when the post-checks fail",
+	"cfgOperation.settle":     "time to wait after the commit before running the post-checks",
+	"cfgOperation.checkpoint": "name or number of the checkpoint to roll back to",
+	"cfgOperation.commit-confirmed": "timeout after which the device rolls the commit back " +
+		"unless it is confirmed after the passing post-checks",
+
+	"group.vars":            "variables of the group's devices",
+	"group.intended-config": "path to the intended config template of the group's devices without their own",
+
+	"normalizeRule.platforms":  "platforms the rule applies to, all platforms when empty",
+	"normalizeRule.commands":   "commands which outputs the rule applies to, all outputs when empty",
+	"normalizeRule.drop-lines": "patterns of the lines removed from the outputs",
+	"normalizeRule.replace":    "replace operations applied to the outputs in order",
+
+	"replaceRule.pattern": "pattern to replace",
+	"replaceRule.with":    "replacement, may reference the pattern groups",
+}
+
+// fieldEnums are the allowed values of the inventory types fields by the type and yaml field names.
+var fieldEnums = map[string][]string{ //nolint:gochecknoglobals
+	"device.platform":           supportedPlatforms,
+	"cfgOperation.type":         cfgOperationTypes,
+	"transports.transport-type": transportTypes,
+	"normalizeRule.platforms":   supportedPlatforms,
+}
+
+// inventorySchema generates the JSON Schema of the inventory from the inventory types.
+func inventorySchema() *jsonSchema {
+	g := &schemaGenerator{defs: map[string]*jsonSchema{}}
+
+	s := g.structSchema(reflect.TypeOf(inventory{}))
+	s.Schema = schemaDraft
+	s.Title = "cmdo inventory"
+	s.Definitions = g.defs
+
+	return s
+}
+
+type schemaGenerator struct {
+	defs map[string]*jsonSchema
+}
+
+// typeSchema returns the schema of the t type. The structs are added to the definitions
+// and referenced.
+func (g *schemaGenerator) typeSchema(t reflect.Type) *jsonSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case durationType:
+		return &jsonSchema{OneOf: []*jsonSchema{
+			{Type: "string", Pattern: durationPattern, Description: "a duration, e.g. 30s or 5m"},
+			{Type: "integer", Description: "a duration in nanoseconds"},
+		}}
+	case chainType:
+		return &jsonSchema{OneOf: []*jsonSchema{
+			{Type: "string"},
+			{Type: "array", Items: &jsonSchema{Type: "string"}},
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if _, ok := g.defs[t.Name()]; !ok {
+			// the placeholder stops the recursion of the self-referencing types
+			g.defs[t.Name()] = &jsonSchema{}
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+
+		return &jsonSchema{Ref: schemaDefsRef + t.Name()}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: g.typeSchema(t.Elem())}
+	case reflect.Slice:
+		return &jsonSchema{Type: "array", Items: g.typeSchema(t.Elem())}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &jsonSchema{Type: "integer"}
+	default:
+		// any value
+		return &jsonSchema{}
+	}
+}
+
+// structSchema returns the schema of the object with the fields of the struct t.
+func (g *schemaGenerator) structSchema(t reflect.Type) *jsonSchema {
+	s := &jsonSchema{
+		Type:                 "object",
+		Description:          typeDescriptions[t.Name()],
+		Properties:           map[string]*jsonSchema{},
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name := yamlName(f)
+		if name == "" {
+			continue
+		}
+
+		fs := g.typeSchema(f.Type)
+
+		key := t.Name() + "." + name
+		fs.Description = fieldDescriptions[key]
+
+		if enum, ok := fieldEnums[key]; ok {
+			if fs.Items != nil {
+				fs.Items.Enum = enum
+			} else {
+				fs.Enum = enum
+			}
+		}
+
+		s.Properties[name] = fs
+	}
+
+	return s
+}
+
+// resolve returns the schema s refers to within the root schema.
+func (root *jsonSchema) resolve(s *jsonSchema) *jsonSchema {
+	for s.Ref != "" {
+		def, ok := root.Definitions[strings.TrimPrefix(s.Ref, schemaDefsRef)]
+		if !ok {
+			return &jsonSchema{}
+		}
+
+		s = def
+	}
+
+	return s
+}
+
+// yamlName returns the yaml name of the exported struct field f, or an empty string
+// if the field is not decoded from yaml.
+func yamlName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+	if f.PkgPath != "" || name == "-" {
+		return ""
+	}
+
+	return name
+}
+
+// runSchema prints the JSON Schema of the inventory.
+func (app *appCfg) runSchema() error {
+	b, err := json.MarshalIndent(inventorySchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(b))
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package commando
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestInventorySchemaDescriptions checks every inventory field is described
+// and every description belongs to an inventory field.
+func TestInventorySchemaDescriptions(t *testing.T) {
+	s := inventorySchema()
+
+	types := map[string]*jsonSchema{"inventory": s}
+	for name, def := range s.Definitions {
+		types[name] = def
+	}
+
+	described := map[string]bool{}
+
+	for name, ts := range types {
+		if ts.Description == "" {
+			t.Errorf("type %s has no description", name)
+		}
+
+		for field, fs := range ts.Properties {
+			described[name+"."+field] = true
+
+			if fs.Description == "" {
+				t.Errorf("field %s.%s has no description", name, field)
+			}
+		}
+	}
+
+	for key := range fieldDescriptions {
+		if !described[key] {
+			t.Errorf("description of the unknown field %s", key)
+		}
+	}
+}
+
+func TestInventorySchema(t *testing.T) {
+	s := inventorySchema()
+
+	if s.Schema != schemaDraft || s.AdditionalProperties != false {
+		t.Fatalf("got root schema %+v", *s)
+	}
+
+	dev := s.resolve(s.Properties["devices"].AdditionalProperties.(*jsonSchema))
+
+	tests := []struct {
+		name string
+		got  *jsonSchema
+		want *jsonSchema
+	}{
+		{
+			name: "platform",
+			got:  dev.Properties["platform"],
+			want: &jsonSchema{Type: "string", Description: fieldDescriptions["device.platform"], Enum: supportedPlatforms},
+		},
+		{
+			name: "credentials chain",
+			got:  dev.Properties["credentials"],
+			want: &jsonSchema{
+				Description: fieldDescriptions["device.credentials"],
+				OneOf: []*jsonSchema{
+					{Type: "string"},
+					{Type: "array", Items: &jsonSchema{Type: "string"}},
+				},
+			},
+		},
+		{
+			name: "cfg operation type",
+			got:  s.resolve(dev.Properties["cfg-operations"].Items).Properties["type"],
+			want: &jsonSchema{Type: "string", Description: fieldDescriptions["cfgOperation.type"], Enum: cfgOperationTypes},
+		},
+		{
+			name: "duration",
+			got:  s.resolve(dev.Properties["cfg-operations"].Items).Properties["settle"],
+			want: &jsonSchema{
+				Description: fieldDescriptions["cfgOperation.settle"],
+				OneOf: []*jsonSchema{
+					{Type: "string", Pattern: durationPattern, Description: "a duration, e.g. 30s or 5m"},
+					{Type: "integer", Description: "a duration in nanoseconds"},
+				},
+			},
+		},
+		{
+			name: "normalisation rule platforms",
+			got:  s.resolve(s.Properties["normalize"].Items).Properties["platforms"],
+			want: &jsonSchema{
+				Type:        "array",
+				Description: fieldDescriptions["normalizeRule.platforms"],
+				Items:       &jsonSchema{Type: "string", Enum: supportedPlatforms},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Fatalf("got %+v, want %+v", *tt.got, *tt.want)
+			}
+		})
+	}
+
+	// the schema is valid JSON with the references to the definitions
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := doc["definitions"].(map[string]interface{})["device"]; !ok {
+		t.Fatalf("schema has no device definition: %s", b)
+	}
+}
@@ -0,0 +1,199 @@
+package commando
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+const (
+	fileSecretPrefix = "file:"
+	execSecretPrefix = "exec:"
+	// password value prompting for the password when the inventory is loaded
+	promptSecretValue = "prompt"
+)
+
+var envSecretRe = regexp.MustCompile(`\$\{env:([A-Za-z_][A-Za-z0-9_]*)\}`) //nolint:gochecknoglobals
+
+// resolveSecret resolves the secret references in the s value:
+//   - ${env:VAR} references are replaced with the values of the environment variables;
+//   - file:/path is replaced with the contents of the file;
+//   - exec:cmd is replaced with the output of the shell command.
+//
+// The trailing newline of the file contents and the command output is removed.
+// Values without references are returned as is.
+func resolveSecret(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, fileSecretPrefix):
+		f := strings.TrimPrefix(s, fileSecretPrefix)
+
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", errSecretReference, err)
+		}
+
+		return strings.TrimRight(string(b), "\r\n"), nil
+	case strings.HasPrefix(s, execSecretPrefix):
+		cmd := exec.Command("sh", "-c", strings.TrimPrefix(s, execSecretPrefix)) //nolint:gosec
+
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				err = fmt.Errorf("%w: %s", err, msg)
+			}
+
+			return "", fmt.Errorf("%w: %s: %v", errSecretReference, s, err)
+		}
+
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+
+	var err error
+
+	resolved := envSecretRe.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envSecretRe.FindStringSubmatch(ref)[1]
+
+		v, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("%w: environment variable %s is not set", errSecretReference, name)
+		}
+
+		return v
+	})
+
+	return resolved, err
+}
+
+// resolve resolves the secret references of the name credentials
+// and prompts for the passwords set to prompt.
+func (c *credentials) resolve(name string) error {
+	for _, f := range []*string{&c.Username, &c.Password, &c.SecondaryPassword} {
+		v, err := resolveSecret(*f)
+		if err != nil {
+			return err
+		}
+
+		*f = v
+	}
+
+	if c.Password == promptSecretValue {
+		p, err := promptSecret(fmt.Sprintf("Password for %s@%s credentials: ", c.Username, name))
+		if err != nil {
+			return err
+		}
+
+		c.Password = p
+	}
+
+	if c.SecondaryPassword == promptSecretValue {
+		p, err := promptSecret(fmt.Sprintf("Secondary password for %s credentials: ", name))
+		if err != nil {
+			return err
+		}
+
+		c.SecondaryPassword = p
+	}
+
+	return nil
+}
+
+// readPassword reads the password of the single-node mode from stdin if requested,
+// or prompts for it if it wasn't provided with the flag or the CMDO_PASSWORD env var.
+func (app *appCfg) readPassword() error {
+	if app.passwdStdin {
+		p, err := readLine(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		app.password = p
+	}
+
+	if app.password != "" {
+		return nil
+	}
+
+	if app.commands == stdinArg {
+		return errStdinInUse
+	}
+
+	p, err := promptSecret(fmt.Sprintf("Password for %s@%s: ", app.username, app.address))
+	if errors.Is(err, errNoTerminal) {
+		return errNoPasswordDefined
+	}
+
+	app.password = p
+
+	if err == nil && p == "" {
+		return errNoPasswordDefined
+	}
+
+	return err
+}
+
+// readLine reads the first line of r without the line ending. The line is read byte by byte,
+// so that the rest of the input is left unread for the --confirm answers.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+
+	b := make([]byte, 1)
+
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+
+			line = append(line, b[0])
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.TrimRight(string(line), "\r"), nil
+}
+
+// resolveCredentials resolves the secret references of the credentials used by the devices.
+// The credentials not used by any device are left intact, so that their commands aren't run.
+// The passwords can't be prompted for when stdin holds the commands.
+func (app *appCfg) resolveCredentials(creds map[string]*credentials, devs map[string]*device) error {
+	used := map[string]struct{}{}
+
+	for _, d := range devs {
+		for _, c := range d.Credentials.names() {
+			used[c] = struct{}{}
+		}
+	}
+
+	for _, name := range sortedKeys(used) {
+		c, ok := creds[name]
+		if !ok {
+			continue
+		}
+
+		if app.commands == stdinArg && (c.Password == promptSecretValue || c.SecondaryPassword == promptSecretValue) {
+			return fmt.Errorf("credentials %s: %w", name, errStdinInUse)
+		}
+
+		if err := c.resolve(name); err != nil {
+			return fmt.Errorf("credentials %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package commando
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir := t.TempDir()
+
+	secretFile := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CMDO_TEST_USER", "admin")
+	t.Setenv("CMDO_TEST_PASS", "s3cret")
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr error
+	}{
+		{value: "plain", want: "plain"},
+		{value: "${env:CMDO_TEST_PASS}", want: "s3cret"},
+		{value: "${env:CMDO_TEST_USER}-${env:CMDO_TEST_PASS}", want: "admin-s3cret"},
+		{value: "$CMDO_TEST_PASS", want: "$CMDO_TEST_PASS"},
+		{value: "${env:CMDO_TEST_MISSING}", wantErr: errSecretReference},
+		{value: "file:" + secretFile, want: "from-file"},
+		{value: "file:" + filepath.Join(dir, "missing"), wantErr: errSecretReference},
+		{value: "exec:printf 'from-exec\\n'", want: "from-exec"},
+		{value: "exec:echo $CMDO_TEST_USER", want: "admin"},
+		{value: "exec:echo denied >&2; exit 1", wantErr: errSecretReference},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := resolveSecret(tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCredentialsUsedOnly(t *testing.T) {
+	t.Setenv("CMDO_TEST_PASS", "s3cret")
+
+	creds := map[string]*credentials{
+		"default": {Username: "admin", Password: "${env:CMDO_TEST_PASS}", SecondaryPassword: "file:/nonexistent"},
+		"unused":  {Username: "admin", Password: "exec:exit 1"},
+	}
+
+	err := (&appCfg{}).resolveCredentials(creds, map[string]*device{"r1": {}})
+	if !errors.Is(err, errSecretReference) {
+		t.Fatalf("got error %v, want %v", err, errSecretReference)
+	}
+
+	creds["default"].SecondaryPassword = "enable"
+
+	if err := (&appCfg{}).resolveCredentials(creds, map[string]*device{"r1": {}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if creds["default"].Password != "s3cret" {
+		t.Fatalf("got password %q, want the resolved one", creds["default"].Password)
+	}
+
+	// the credentials not used by any device are left intact
+	if creds["unused"].Password != "exec:exit 1" {
+		t.Fatalf("unused credentials were resolved to %q", creds["unused"].Password)
+	}
+}
+
+// withStdin replaces os.Stdin with a pipe holding the input for the duration of the test
+// and returns the read end of the pipe.
+func withStdin(t *testing.T, input string) *os.File {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.WriteString(input); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Close()
+
+	stdin := os.Stdin
+	os.Stdin = r
+
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		r.Close()
+	})
+
+	return r
+}
+
+func TestReadPasswordStdin(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     string
+		wantRest string
+	}{
+		{"with confirm answers", "s3cret\na\n", "s3cret", "a\n"},
+		{"crlf", "s3cret\r\ny\n", "s3cret", "y\n"},
+		{"without newline", "s3cret", "s3cret", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := withStdin(t, tt.input)
+
+			app := &appCfg{passwdStdin: true}
+			if err := app.readPassword(); err != nil {
+				t.Fatal(err)
+			}
+
+			if app.password != tt.want {
+				t.Fatalf("got password %q, want %q", app.password, tt.want)
+			}
+
+			// the rest of stdin is left for the --confirm prompt
+			rest, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(rest) != tt.wantRest {
+				t.Fatalf("got the rest of stdin %q, want %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestReadPasswordErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		app     *appCfg
+		wantErr error
+	}{
+		{"empty stdin", &appCfg{passwdStdin: true}, errNoPasswordDefined},
+		{"commands from stdin", &appCfg{commands: stdinArg}, errStdinInUse},
+		{"no terminal to prompt", &appCfg{}, errNoPasswordDefined},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withStdin(t, "")
+
+			if err := tt.app.readPassword(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveCredentialsPrompt(t *testing.T) {
+	withStdin(t, "")
+
+	devs := map[string]*device{"r1": {Credentials: credentialChain{"lab"}}}
+
+	tests := []struct {
+		name    string
+		app     *appCfg
+		creds   *credentials
+		wantErr error
+	}{
+		{"commands from stdin", &appCfg{commands: stdinArg}, &credentials{Password: promptSecretValue}, errStdinInUse},
+		{"no terminal to prompt", &appCfg{}, &credentials{SecondaryPassword: promptSecretValue}, errNoTerminal},
+		{"not prompted", &appCfg{commands: stdinArg}, &credentials{Password: "admin"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := map[string]*credentials{"lab": tt.creds, "unused": {Password: promptSecretValue}}
+
+			if err := tt.app.resolveCredentials(creds, devs); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
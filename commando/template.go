@@ -0,0 +1,378 @@
+package commando
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// group is a named set of devices sharing the variables and the intended config.
+// Devices join the groups by listing them in their groups field.
+type group struct {
+	Vars           map[string]interface{} `yaml:"vars,omitempty"`
+	IntendedConfig string                 `yaml:"intended-config,omitempty"`
+}
+
+// templateData is the data available to the templates rendered for a device.
+type templateData struct {
+	Name     string
+	Address  string
+	Platform string
+	Tags     []string
+	Groups   []string
+	// variables merged from the inventory, groups and device levels.
+	Vars map[string]interface{}
+}
+
+func newTemplateData(name string, d *device) *templateData {
+	return &templateData{
+		Name:     name,
+		Address:  d.Address,
+		Platform: d.Platform,
+		Tags:     d.Tags,
+		Groups:   d.Groups,
+		Vars:     d.vars,
+	}
+}
+
+// renderTemplate renders the text template named name with the given data.
+func renderTemplate(name, text string, data *templateData) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Funcs(templateFuncs()).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	b := &strings.Builder{}
+	if err := t.Execute(b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// resolveVars merges the variables of every device: inventory vars are overridden
+// by the vars of the device's groups in the order they are listed,
+// which are overridden by the device's own vars.
+// The devices without their own intended-config take it from the last of their groups setting it.
+func (i *inventory) resolveVars() error {
+	for name, d := range i.Devices {
+		d.vars = map[string]interface{}{}
+
+		for k, v := range i.Vars {
+			d.vars[k] = v
+		}
+
+		intended := ""
+
+		for _, g := range d.Groups {
+			grp, ok := i.Groups[g]
+			if !ok {
+				return fmt.Errorf("%w: %s, device %s", errUnknownGroup, g, name)
+			}
+
+			for k, v := range grp.Vars {
+				d.vars[k] = v
+			}
+
+			if grp.IntendedConfig != "" {
+				intended = grp.IntendedConfig
+			}
+		}
+
+		if d.IntendedConfig == "" {
+			d.IntendedConfig = intended
+		}
+
+		for k, v := range d.Vars {
+			d.vars[k] = v
+		}
+	}
+
+	return nil
+}
+
+// renderDevices renders the commands and configs of the devices as templates.
+// The contents of the commands and configs files are rendered and inlined,
+// so that the devices carry the final commands and configs sent to them.
+func renderDevices(devs map[string]*device) error {
+	for name, d := range devs {
+		if err := d.render(name); err != nil {
+			return fmt.Errorf("device %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *device) render(name string) error {
+	var err error
+
+	data := newTemplateData(name, d)
+
+	cmds, err := renderLines(d.SendCommandsFromFile, d.SendCommands, data)
+	if err != nil {
+		return err
+	}
+
+	d.SendCommands, d.SendCommandsFromFile = cmds, ""
+
+	cfgs, err := renderLines(d.SendConfigsFromFile, d.SendConfigs, data)
+	if err != nil {
+		return err
+	}
+
+	d.SendConfigs, d.SendConfigsFromFile = cfgs, ""
+
+	for _, op := range d.CfgOperations {
+		name, text := "config", op.Config
+
+		if op.ConfigFromFile != "" {
+			b, err := os.ReadFile(op.ConfigFromFile)
+			if err != nil {
+				return err
+			}
+
+			name, text = op.ConfigFromFile, string(b)
+		}
+
+		if text == "" {
+			continue
+		}
+
+		if op.Config, err = renderTemplate(name, text, data); err != nil {
+			return err
+		}
+
+		op.ConfigFromFile = ""
+	}
+
+	return nil
+}
+
+// renderLines renders the lines of the f file, followed by the lines,
+// and returns the resulting lines. The file is rendered as a whole, so that
+// the template actions may span multiple lines.
+func renderLines(f string, lines []string, data *templateData) ([]string, error) {
+	var rendered []string
+
+	if f != "" {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := renderTemplate(f, string(b), data)
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(strings.NewReader(s))
+		for scanner.Scan() {
+			rendered = append(rendered, scanner.Text())
+		}
+	}
+
+	for idx, l := range lines {
+		s, err := renderTemplate(fmt.Sprintf("line %d", idx), l, data)
+		if err != nil {
+			return nil, err
+		}
+
+		rendered = append(rendered, s)
+	}
+
+	return rendered, nil
+}
+
+// templateFuncs returns the helper functions available in the templates.
+// They follow the names and the arguments order of the sprig library.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"default":    defaultValue,
+		"empty":      isEmpty,
+		"coalesce":   coalesce,
+		"ternary":    ternary,
+		"required":   required,
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      title,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(p, s string) string { return strings.TrimPrefix(s, p) },
+		"trimSuffix": func(p, s string) string { return strings.TrimSuffix(s, p) },
+		"replace":    func(o, n, s string) string { return strings.ReplaceAll(s, o, n) },
+		"contains":   func(sub, s string) bool { return strings.Contains(s, sub) },
+		"hasPrefix":  func(p, s string) bool { return strings.HasPrefix(s, p) },
+		"hasSuffix":  func(p, s string) bool { return strings.HasSuffix(s, p) },
+		"splitList":  func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"indent":     indent,
+		"nindent":    func(n int, s string) string { return "\n" + indent(n, s) },
+		"quote":      func(v interface{}) string { return strconv.Quote(toString(v)) },
+		"squote":     func(v interface{}) string { return "'" + toString(v) + "'" },
+		"toString":   toString,
+		"toYaml":     toYaml,
+		"list":       func(v ...interface{}) []interface{} { return v },
+		"dict":       dict,
+		"atoi":       func(s string) int { i, _ := strconv.Atoi(s); return i },
+		"int":        toInt,
+		"add":        func(a, b interface{}) int64 { return toInt(a) + toInt(b) },
+		"sub":        func(a, b interface{}) int64 { return toInt(a) - toInt(b) },
+		"mul":        func(a, b interface{}) int64 { return toInt(a) * toInt(b) },
+		"div":        func(a, b interface{}) int64 { return toInt(a) / toInt(b) },
+		"mod":        func(a, b interface{}) int64 { return toInt(a) % toInt(b) },
+	}
+}
+
+func defaultValue(d interface{}, v ...interface{}) interface{} {
+	if len(v) == 0 || isEmpty(v[0]) {
+		return d
+	}
+
+	return v[0]
+}
+
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return rv.IsZero()
+	}
+}
+
+func coalesce(v ...interface{}) interface{} {
+	for _, e := range v {
+		if !isEmpty(e) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func ternary(t, f interface{}, cond bool) interface{} {
+	if cond {
+		return t
+	}
+
+	return f
+}
+
+func required(msg string, v interface{}) (interface{}, error) {
+	if isEmpty(v) {
+		return nil, fmt.Errorf("%w: %s", errRequiredValue, msg)
+	}
+
+	return v, nil
+}
+
+// title upper-cases the first letter of every word of s, keeping the whitespace as is.
+func title(s string) string {
+	b := &strings.Builder{}
+	b.Grow(len(s))
+
+	wordStart := true
+
+	for len(s) != 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+
+		if wordStart {
+			r = unicode.ToTitle(r)
+		}
+
+		wordStart = unicode.IsSpace(r)
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func join(sep string, v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return toString(v)
+	}
+
+	s := make([]string, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		s = append(s, toString(rv.Index(i).Interface()))
+	}
+
+	return strings.Join(s, sep)
+}
+
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func toString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case fmt.Stringer:
+		return val.String()
+	}
+
+	return fmt.Sprint(v)
+}
+
+func toYaml(v interface{}) (string, error) {
+	b, err := yaml.Marshal(v)
+
+	return strings.TrimSuffix(string(b), "\n"), err
+}
+
+func dict(v ...interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(v)/2)
+
+	for i := 0; i+1 < len(v); i += 2 {
+		m[toString(v[i])] = v[i+1]
+	}
+
+	return m
+}
+
+func toInt(v interface{}) int64 {
+	switch val := v.(type) {
+	case int:
+		return int64(val)
+	case int64:
+		return val
+	case int32:
+		return int64(val)
+	case uint:
+		return int64(val)
+	case uint64:
+		return int64(val)
+	case float64:
+		return int64(val)
+	case string:
+		i, _ := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+
+		return i
+	}
+
+	return 0
+}
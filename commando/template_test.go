@@ -0,0 +1,183 @@
+package commando
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	data := &templateData{
+		Name: "leaf1",
+		Vars: map[string]interface{}{
+			"asn":     65001,
+			"uplinks": []interface{}{"Ethernet1", "Ethernet2"},
+			"empty":   "",
+			"site":    "dc1",
+		},
+	}
+
+	tests := []struct {
+		text    string
+		want    string
+		wantErr error
+	}{
+		{text: `{{ title "north  east" }}`, want: "North  East"},
+		{text: `{{ title "élan über ǆungla" }}`, want: "Élan Über ǅungla"},
+		{text: `{{ upper .Name }}`, want: "LEAF1"},
+		{text: `{{ .Vars.empty | default "uplink" }}`, want: "uplink"},
+		{text: `{{ .Vars.site | default "dc0" }}`, want: "dc1"},
+		{text: `{{ coalesce .Vars.empty "" .Vars.site }}`, want: "dc1"},
+		{text: `{{ ternary "yes" "no" (empty .Vars.empty) }}`, want: "yes"},
+		{text: `{{ join ", " .Vars.uplinks }}`, want: "Ethernet1, Ethernet2"},
+		{text: `{{ splitList "." "10.0.0.1" | join "-" }}`, want: "10-0-0-1"},
+		{text: `{{ "a\nb" | indent 2 }}`, want: "  a\n  b"},
+		{text: `x{{ "a" | nindent 1 }}`, want: "x\n a"},
+		{text: `{{ quote .Vars.asn }} {{ squote .Name }}`, want: `"65001" 'leaf1'`},
+		{text: `{{ replace "Ethernet" "Et" "Ethernet1" }}`, want: "Et1"},
+		{text: `{{ trimPrefix "leaf" .Name }}{{ trimSuffix "1" .Name }}`, want: "1leaf"},
+		{text: `{{ add .Vars.asn 1 }} {{ sub 10 4 }} {{ mul 3 "4" }} {{ div 7 2 }} {{ mod 7 2 }}`, want: "65002 6 12 3 1"},
+		{text: `{{ atoi "42" | add 1 }}`, want: "43"},
+		{text: `{{ (dict "a" 1 "b" (list 1 2)) | toYaml }}`, want: "a: 1\nb:\n- 1\n- 2"},
+		{text: `{{ hasPrefix "leaf" .Name }} {{ contains "af" .Name }}`, want: "true true"},
+		{text: `{{ "{{" }} literal }}`, want: "{{ literal }}"},
+		{text: "{{`{{ literal }}`}}", want: "{{ literal }}"},
+		{text: `{{ required "asn is required" .Vars.empty }}`, wantErr: errRequiredValue},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := renderTemplate("test", tt.text, data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateErrors(t *testing.T) {
+	data := &templateData{Vars: map[string]interface{}{}}
+
+	for _, text := range []string{
+		"{{ .Vars.missing }}",
+		"{{ .Missing }}",
+		"banner {{ maintenance }}",
+		"{{ if }}",
+	} {
+		if _, err := renderTemplate("test", text, data); err == nil {
+			t.Errorf("%q rendered without an error", text)
+		}
+	}
+}
+
+func TestRenderDevices(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"commands.txt": "show interfaces {{ .Vars.uplink }}\n{{ range .Tags }}show {{ . }}\n{{ end }}",
+		"bgp.tpl":      "router bgp {{ .Vars.asn }}\n   router-id {{ .Address }}\n",
+	}
+
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	i := &inventory{
+		Vars:   map[string]interface{}{"asn": 65000, "uplink": "Ethernet1"},
+		Groups: map[string]*group{"leaf": {Vars: map[string]interface{}{"asn": 65001}}},
+		Devices: map[string]*device{
+			"leaf1": {
+				Address:              "10.0.0.1",
+				Tags:                 []string{"bgp", "lldp"},
+				Groups:               []string{"leaf"},
+				Vars:                 map[string]interface{}{"uplink": "Ethernet49"},
+				SendCommandsFromFile: filepath.Join(dir, "commands.txt"),
+				SendCommands:         []string{"show version | include {{ .Name }}"},
+				SendConfigs:          []string{"hostname {{ .Name }}"},
+				CfgOperations: []*cfgOperation{
+					{OperationType: loadConfigOp, ConfigFromFile: filepath.Join(dir, "bgp.tpl")},
+					{OperationType: getConfigOp},
+				},
+			},
+		},
+	}
+
+	if err := i.resolveVars(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := renderDevices(i.Devices); err != nil {
+		t.Fatal(err)
+	}
+
+	d := i.Devices["leaf1"]
+
+	want := &device{
+		Address: "10.0.0.1",
+		Tags:    []string{"bgp", "lldp"},
+		Groups:  []string{"leaf"},
+		Vars:    map[string]interface{}{"uplink": "Ethernet49"},
+		SendCommands: []string{
+			"show interfaces Ethernet49",
+			"show bgp",
+			"show lldp",
+			"show version | include leaf1",
+		},
+		SendConfigs: []string{"hostname leaf1"},
+		CfgOperations: []*cfgOperation{
+			{OperationType: loadConfigOp, Config: "router bgp 65001\n   router-id 10.0.0.1\n"},
+			{OperationType: getConfigOp},
+		},
+		vars: map[string]interface{}{"asn": 65001, "uplink": "Ethernet49"},
+	}
+
+	if !reflect.DeepEqual(d, want) {
+		t.Fatalf("got device %+v, want %+v", *d, *want)
+	}
+}
+
+func TestRenderDevicesError(t *testing.T) {
+	devs := map[string]*device{"r1": {SendCommands: []string{"show {{ .Vars.missing }}"}}}
+
+	err := renderDevices(devs)
+	if err == nil || !strings.HasPrefix(err.Error(), "device r1: ") {
+		t.Fatalf("got error %v, want the device r1 error", err)
+	}
+}
+
+func TestLoadInventoryFromFlagsRenders(t *testing.T) {
+	app := &appCfg{
+		platform: "arista_eos",
+		address:  "192.0.2.1,192.0.2.2",
+		username: "admin",
+		password: "admin",
+		commands: "show ip route {{ .Address }}/32",
+		configs:  "hostname {{ .Name | replace \".\" \"-\" }}",
+	}
+
+	i := &inventory{}
+	if err := app.loadInventoryFromFlags(i); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, addr := range []string{"192.0.2.1", "192.0.2.2"} {
+		d := i.Devices[addr]
+
+		if want := []string{"show ip route " + addr + "/32"}; !reflect.DeepEqual(d.SendCommands, want) {
+			t.Errorf("got commands %q, want %q", d.SendCommands, want)
+		}
+
+		if want := []string{"hostname " + strings.ReplaceAll(addr, ".", "-")}; !reflect.DeepEqual(d.SendConfigs, want) {
+			t.Errorf("got configs %q, want %q", d.SendConfigs, want)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package commando
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/scrapli/scrapligocfg"
+	cfgresponse "github.com/scrapli/scrapligocfg/response"
+	cfgutil "github.com/scrapli/scrapligocfg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+const transactionOp = "Transaction"
+
+// txCoordinator coordinates the two-phase commit of the load-config operations
+// across the devices. In the first phase every participating device loads the candidate
+// config and votes. The candidates are committed only if all devices loaded them successfully,
+// otherwise they are aborted on all devices.
+// In the confirm mode the operator decides which of the loaded candidates are committed.
+// All methods are safe to call on a nil coordinator, which means the transaction mode is off.
+type txCoordinator struct {
+	mu sync.Mutex
+
+	votePending map[string]struct{}                  // participants which haven't voted yet
+	failed      []string                             // participants which failed to load the candidate
+	diffs       map[string]*cfgresponse.DiffResponse // candidate diffs of the participants
+	approved    map[string]bool                      // participants approved to commit
+	decided     chan struct{}                        // closed once the commit decision is made
+	reason      string                               // why the participants not approved were aborted
+
+	// allOrNothing aborts all participants if any of them failed to load the candidate.
+	allOrNothing bool
+	// confirm asks the operator which participants to commit, all are approved when nil.
+	confirm func(diffs map[string]*cfgresponse.DiffResponse, failed []string) map[string]bool
+
+	commitPending map[string]struct{} // approved participants which haven't reported the commit yet
+	commitFailed  []string            // participants which failed to commit
+	committed     chan struct{}       // closed once all approved participants reported the commit
+}
+
+func newTxCoordinator(participants []string) *txCoordinator {
+	t := &txCoordinator{
+		votePending:   map[string]struct{}{},
+		diffs:         map[string]*cfgresponse.DiffResponse{},
+		approved:      map[string]bool{},
+		decided:       make(chan struct{}),
+		commitPending: map[string]struct{}{},
+		committed:     make(chan struct{}),
+	}
+
+	for _, p := range participants {
+		t.votePending[p] = struct{}{}
+	}
+
+	if len(participants) == 0 {
+		close(t.decided)
+		close(t.committed)
+	}
+
+	return t
+}
+
+// newTxCoordinator returns the transaction coordinator for the devices having a committing
+// load-config operation, or nil if both the transaction and confirm modes are off.
+func (app *appCfg) newTxCoordinator(devs map[string]*device) (*txCoordinator, error) {
+	if !app.transaction && !app.confirm {
+		return nil, nil
+	}
+
+	var participants []string
+
+	for name, d := range devs {
+		n := 0
+
+		for _, op := range d.CfgOperations {
+			if op.OperationType == loadConfigOp && op.Commit {
+				n++
+			}
+		}
+
+		if n > 1 {
+			return nil, fmt.Errorf("%w: device %s", errMultipleTxOperations, name)
+		}
+
+		if n == 1 {
+			participants = append(participants, name)
+		}
+	}
+
+	t := newTxCoordinator(participants)
+	t.allOrNothing = app.transaction
+
+	if app.confirm {
+		t.confirm = confirmCommit
+	}
+
+	return t, nil
+}
+
+// participates returns true if the device takes part in the transaction.
+func (t *txCoordinator) participates(name string) bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, pending := t.votePending[name]
+	_, voted := t.diffs[name]
+
+	return pending || voted || contains(t.failed, name)
+}
+
+// prepare records the outcome of loading the candidate config on the device
+// and blocks until all participants voted. It returns true if the device should commit.
+func (t *txCoordinator) prepare(name string, ok bool, diff *cfgresponse.DiffResponse) bool {
+	t.vote(name, ok, diff)
+
+	<-t.decided
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.approved[name]
+}
+
+func (t *txCoordinator) vote(name string, ok bool, diff *cfgresponse.DiffResponse) {
+	t.mu.Lock()
+
+	if _, pending := t.votePending[name]; !pending {
+		t.mu.Unlock()
+
+		return
+	}
+
+	delete(t.votePending, name)
+
+	if ok {
+		t.diffs[name] = diff
+	} else {
+		t.failed = append(t.failed, name)
+	}
+
+	if len(t.votePending) != 0 {
+		t.mu.Unlock()
+
+		return
+	}
+
+	// the last voter decides without holding the lock, as the operator may take long to confirm
+	diffs := make(map[string]*cfgresponse.DiffResponse, len(t.diffs))
+	for n, d := range t.diffs {
+		diffs[n] = d
+	}
+
+	sort.Strings(t.failed)
+	failed := append([]string(nil), t.failed...)
+
+	t.mu.Unlock()
+
+	approved, reason := t.decide(diffs, failed)
+
+	t.mu.Lock()
+
+	t.reason = reason
+
+	for n := range diffs {
+		if approved[n] {
+			t.approved[n] = true
+			t.commitPending[n] = struct{}{}
+		}
+	}
+
+	if len(t.commitPending) == 0 {
+		close(t.committed)
+	}
+
+	t.mu.Unlock()
+
+	close(t.decided)
+}
+
+// decide returns the participants approved to commit out of the ones which loaded the candidate
+// successfully, and the reason the others are aborted.
+// In the transaction mode nothing is approved if any of the participants failed,
+// in the confirm mode only the participants approved by the operator are.
+func (t *txCoordinator) decide(
+	diffs map[string]*cfgresponse.DiffResponse,
+	failed []string,
+) (map[string]bool, string) {
+	if t.allOrNothing && len(failed) != 0 {
+		reason := "transaction aborted, load-config failed on: " + strings.Join(failed, ", ")
+		log.Error(reason)
+
+		return nil, reason
+	}
+
+	if t.confirm != nil {
+		return t.confirm(diffs, failed), "commit not approved by the operator"
+	}
+
+	approved := make(map[string]bool, len(diffs))
+	for name := range diffs {
+		approved[name] = true
+	}
+
+	return approved, ""
+}
+
+// abortReason returns the reason the device was not approved to commit.
+func (t *txCoordinator) abortReason() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.reason
+}
+
+// commitDone records the outcome of the commit on the approved device.
+func (t *txCoordinator) commitDone(name string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, pending := t.commitPending[name]; !pending {
+		return
+	}
+
+	delete(t.commitPending, name)
+
+	if err != nil {
+		t.commitFailed = append(t.commitFailed, name)
+	}
+
+	if len(t.commitPending) == 0 {
+		close(t.committed)
+	}
+}
+
+// commitFailures blocks until all approved participants reported the commit
+// and returns the devices which failed to commit.
+func (t *txCoordinator) commitFailures() []string {
+	<-t.committed
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sort.Strings(t.commitFailed)
+
+	return t.commitFailed
+}
+
+// release makes sure the device doesn't block the transaction when it fails
+// before voting or reporting the commit.
+func (t *txCoordinator) release(name string) {
+	if t == nil {
+		return
+	}
+
+	t.vote(name, false, nil)
+	t.commitDone(name, errNotCommitted)
+}
+
+// runTxCommit runs the second phase of the transaction for the device:
+// the candidate is committed if it was approved, aborted otherwise.
+// When txRollback is set and some of the participants failed to commit,
+// the devices that committed restore the config saved before the load.
+// The candidate is committed with the commit function.
+// The returned bool is true if the candidate was committed and not rolled back.
+func (app *appCfg) runTxCommit(
+	name string,
+	c *scrapligocfg.Cfg,
+	diff *cfgresponse.DiffResponse,
+	saved string,
+	commit func() (*cfgresponse.Response, error),
+	opts ...cfgutil.Option,
+) ([]interface{}, bool, error) {
+	var responses []interface{}
+
+	if !app.tx.prepare(name, true, diff) {
+		if _, err := c.AbortConfig(); err != nil {
+			log.Errorf("abort-config operation failed for device %s; error: %+v\n", name, err)
+
+			return nil, false, err
+		}
+
+		r := cfgresponse.NewResponse(transactionOp, c.Conn.Transport.GetHost())
+		r.Record(nil, app.tx.abortReason())
+
+		return append(responses, r), false, nil
+	}
+
+	r, err := commit()
+
+	// the commit not confirmed after the failed post-checks fails the transaction as well
+	app.tx.commitDone(name, err)
+
+	if err != nil {
+		responses, err = rolledBackResponses(append(responses, r), err)
+
+		return responses, false, err
+	}
+
+	responses = append(responses, r)
+
+	if !app.txRollback {
+		return responses, true, nil
+	}
+
+	if failed := app.tx.commitFailures(); len(failed) != 0 {
+		log.Warnf("commit failed on %s, rolling back the config of device %s",
+			strings.Join(failed, ", "), name)
+
+		rr, err := restoreConfig(name, c, saved,
+			"transaction commit failed on: "+strings.Join(failed, ", "), opts...)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return append(responses, rr), false, nil
+	}
+
+	return responses, true, nil
+}
@@ -0,0 +1,218 @@
+package commando
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	cfgresponse "github.com/scrapli/scrapligocfg/response"
+)
+
+func TestTxCoordinator(t *testing.T) {
+	errCommit := errors.New("commit failed")
+
+	tests := []struct {
+		name         string
+		votes        map[string]bool // participants and their load-config outcomes
+		allOrNothing bool
+		confirm      []string         // participants approved by the operator, confirm mode is off when nil
+		commits      map[string]error // commit outcomes of the approved participants
+		wantApproved []string
+		wantReason   string
+		wantFailed   []string
+	}{
+		{
+			name:         "all loaded",
+			votes:        map[string]bool{"r1": true, "r2": true},
+			allOrNothing: true,
+			commits:      map[string]error{"r1": nil, "r2": nil},
+			wantApproved: []string{"r1", "r2"},
+		},
+		{
+			name:         "load failed in transaction mode",
+			votes:        map[string]bool{"r1": true, "r2": false, "r3": false},
+			allOrNothing: true,
+			wantReason:   "transaction aborted, load-config failed on: r2, r3",
+		},
+		{
+			name:         "load failed without transaction mode",
+			votes:        map[string]bool{"r1": true, "r2": false},
+			commits:      map[string]error{"r1": nil},
+			wantApproved: []string{"r1"},
+		},
+		{
+			name:         "operator approved some",
+			votes:        map[string]bool{"r1": true, "r2": true, "r3": true},
+			confirm:      []string{"r1", "r3"},
+			commits:      map[string]error{"r1": nil, "r3": nil},
+			wantApproved: []string{"r1", "r3"},
+			wantReason:   "commit not approved by the operator",
+		},
+		{
+			name:       "operator approved none",
+			votes:      map[string]bool{"r1": true},
+			confirm:    []string{},
+			wantReason: "commit not approved by the operator",
+		},
+		{
+			name:         "commit failed",
+			votes:        map[string]bool{"r1": true, "r2": true, "r3": true},
+			allOrNothing: true,
+			commits:      map[string]error{"r1": nil, "r2": errCommit, "r3": errCommit},
+			wantApproved: []string{"r1", "r2", "r3"},
+			wantFailed:   []string{"r2", "r3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := newTxCoordinator(sortedKeys(tt.votes))
+			tx.allOrNothing = tt.allOrNothing
+
+			if tt.confirm != nil {
+				tx.confirm = func(diffs map[string]*cfgresponse.DiffResponse, _ []string) map[string]bool {
+					approved := map[string]bool{}
+
+					for _, name := range tt.confirm {
+						// the operator is asked without the coordinator lock held
+						if _, ok := diffs[name]; ok && tx.participates(name) {
+							approved[name] = true
+						}
+					}
+
+					return approved
+				}
+			}
+
+			var (
+				wg       sync.WaitGroup
+				mu       sync.Mutex
+				approved []string
+			)
+
+			for name, ok := range tt.votes {
+				wg.Add(1)
+
+				go func(name string, ok bool) {
+					defer wg.Done()
+
+					if !tx.prepare(name, ok, cfgresponse.NewDiffResponse(name)) {
+						return
+					}
+
+					mu.Lock()
+					approved = append(approved, name)
+					mu.Unlock()
+
+					tx.commitDone(name, tt.commits[name])
+				}(name, ok)
+			}
+
+			wg.Wait()
+
+			sort.Strings(approved)
+
+			if !reflect.DeepEqual(approved, tt.wantApproved) {
+				t.Fatalf("got approved %q, want %q", approved, tt.wantApproved)
+			}
+
+			if got := tx.abortReason(); got != tt.wantReason {
+				t.Fatalf("got abort reason %q, want %q", got, tt.wantReason)
+			}
+
+			if got := tx.commitFailures(); !reflect.DeepEqual(got, tt.wantFailed) {
+				t.Fatalf("got commit failures %q, want %q", got, tt.wantFailed)
+			}
+		})
+	}
+}
+
+// TestTxCoordinatorRelease checks the participants failing before the vote or the commit
+// don't block the others.
+func TestTxCoordinatorRelease(t *testing.T) {
+	tx := newTxCoordinator([]string{"r1", "r2", "r3"})
+
+	// r3 fails before loading the candidate
+	tx.release("r3")
+
+	var wg sync.WaitGroup
+
+	for _, name := range []string{"r1", "r2"} {
+		wg.Add(1)
+
+		go func(name string) {
+			defer wg.Done()
+
+			if !tx.prepare(name, true, nil) {
+				t.Errorf("%s wasn't approved", name)
+
+				return
+			}
+
+			// r2 fails before reporting the commit
+			if name == "r2" {
+				tx.release(name)
+
+				return
+			}
+
+			tx.commitDone(name, nil)
+		}(name)
+	}
+
+	wg.Wait()
+
+	if got, want := tx.commitFailures(), []string{"r2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got commit failures %q, want %q", got, want)
+	}
+
+	if tx.participates("r4") {
+		t.Fatal("r4 participates in the transaction")
+	}
+
+	var off *txCoordinator
+	if off.participates("r1") {
+		t.Fatal("r1 participates in the transaction which is off")
+	}
+
+	off.release("r1")
+}
+
+func TestNewTxCoordinator(t *testing.T) {
+	commit := &cfgOperation{OperationType: loadConfigOp, Commit: true}
+	load := &cfgOperation{OperationType: loadConfigOp}
+
+	devs := map[string]*device{
+		"r1": {CfgOperations: []*cfgOperation{commit}},
+		"r2": {CfgOperations: []*cfgOperation{load}},
+		"r3": {},
+	}
+
+	app := &appCfg{}
+
+	tx, err := app.newTxCoordinator(devs)
+	if err != nil || tx != nil {
+		t.Fatalf("got coordinator %v and error %v with the transaction mode off", tx, err)
+	}
+
+	app.transaction = true
+
+	tx, err = app.newTxCoordinator(devs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{"r1": true, "r2": false, "r3": false} {
+		if got := tx.participates(name); got != want {
+			t.Errorf("%s participates = %v, want %v", name, got, want)
+		}
+	}
+
+	devs["r2"].CfgOperations = []*cfgOperation{commit, commit}
+
+	if _, err := app.newTxCoordinator(devs); !errors.Is(err, errMultipleTxOperations) {
+		t.Fatalf("got error %v, want %v", err, errMultipleTxOperations)
+	}
+}
@@ -0,0 +1,684 @@
+package commando
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scrapli/scrapligo/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))  //nolint:gochecknoglobals
+	chainType    = reflect.TypeOf(credentialChain{}) //nolint:gochecknoglobals
+
+	yamlErrLineRe = regexp.MustCompile(`^yaml: line (\d+): (.*)$`) //nolint:gochecknoglobals
+
+	// typeNames are the names of the schema types in the problems.
+	typeNames = map[string]string{ //nolint:gochecknoglobals
+		"object":  "a mapping",
+		"array":   "a list",
+		"string":  "a string",
+		"boolean": "a boolean",
+		"integer": "an integer",
+	}
+)
+
+// inventoryProblem is a problem found in the inventory file at the position of the offending node.
+type inventoryProblem struct {
+	file string
+	line int
+	col  int
+	path string
+	msg  string
+}
+
+// inventoryValidator validates the inventory files and collects all the problems found in them.
+type inventoryValidator struct {
+	file   string // file being validated
+	schema *jsonSchema
+	// files converted from the ansible inventory, their problems are reported without the positions
+	generated map[string]bool
+	problems  []*inventoryProblem
+}
+
+// validateInventory validates the inventory files and returns the problems found
+// formatted as file:line:column: path: problem, in the order they appear in the files.
+func validateInventory(files []*inventoryFile) []string {
+	v := &inventoryValidator{schema: inventorySchema(), generated: map[string]bool{}}
+
+	order := map[string]int{}
+
+	for idx, f := range files {
+		v.file = f.path
+		order[f.path] = idx
+		v.generated[f.path] = f.generated
+
+		switch {
+		case f.parseErr != nil:
+			v.addSyntaxError(f.parseErr, f.splice)
+		case f.root == nil:
+			v.add(&yaml.Node{Line: 1, Column: 1}, "", "inventory is empty")
+		default:
+			v.checkNode(f.root, v.schema, "")
+		}
+	}
+
+	v.checkInventory(files)
+
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		pi, pj := v.problems[i], v.problems[j]
+
+		switch {
+		case pi.file != pj.file:
+			return order[pi.file] < order[pj.file]
+		case pi.line != pj.line:
+			return pi.line < pj.line
+		default:
+			return pi.col < pj.col
+		}
+	})
+
+	return v.messages()
+}
+
+func (v *inventoryValidator) add(n *yaml.Node, path, format string, args ...interface{}) {
+	p := &inventoryProblem{
+		file: v.file,
+		line: n.Line,
+		col:  n.Column,
+		path: path,
+		msg:  fmt.Sprintf(format, args...),
+	}
+
+	if v.generated[v.file] {
+		p.line, p.col = 0, 0
+	}
+
+	v.problems = append(v.problems, p)
+}
+
+// position returns the position of the node n in the file f.
+func position(f *inventoryFile, n *yaml.Node) string {
+	if f.generated {
+		return f.path
+	}
+
+	return fmt.Sprintf("%s:%d:%d", f.path, n.Line, n.Column)
+}
+
+// addSyntaxError adds the yaml parser error, keeping the line it reports
+// mapped back to the line of the file.
+func (v *inventoryValidator) addSyntaxError(err error, splice *credentialsSplice) {
+	p := &inventoryProblem{file: v.file, line: 1, col: 1, msg: err.Error()}
+
+	if m := yamlErrLineRe.FindStringSubmatch(err.Error()); m != nil {
+		p.line, _ = strconv.Atoi(m[1])
+		p.line = splice.fileLine(p.line)
+		p.col = 0
+		p.msg = m[2]
+	}
+
+	v.problems = append(v.problems, p)
+}
+
+func (v *inventoryValidator) messages() []string {
+	msgs := make([]string, 0, len(v.problems))
+
+	for _, p := range v.problems {
+		pos := p.file
+		if p.line != 0 {
+			pos += fmt.Sprintf(":%d", p.line)
+		}
+
+		if p.col != 0 {
+			pos += fmt.Sprintf(":%d", p.col)
+		}
+
+		if p.path != "" {
+			pos += ": " + p.path
+		}
+
+		msgs = append(msgs, pos+": "+p.msg)
+	}
+
+	return msgs
+}
+
+// checkNode checks the node n holds a value valid against the s schema of the inventory.
+// Unknown fields and duplicate keys are reported.
+func (v *inventoryValidator) checkNode(n *yaml.Node, s *jsonSchema, path string) {
+	n = resolveAlias(n)
+	s = v.schema.resolve(s)
+
+	if n.Tag == "!!null" {
+		return
+	}
+
+	if len(s.OneOf) != 0 {
+		v.checkOneOf(n, s, path)
+
+		return
+	}
+
+	if !nodeHasType(n, s.Type) {
+		v.add(n, path, "expected %s", typeNames[s.Type])
+
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		v.forEachKey(n, path, func(k, val *yaml.Node) {
+			ps, ok := s.Properties[k.Value]
+			if !ok {
+				ps, ok = s.AdditionalProperties.(*jsonSchema)
+			}
+
+			if !ok {
+				v.add(k, path, "unknown field %q", k.Value)
+
+				return
+			}
+
+			v.checkNode(val, ps, joinPath(path, k.Value))
+		})
+	case "array":
+		for idx, e := range n.Content {
+			v.checkNode(e, s.Items, fmt.Sprintf("%s[%d]", path, idx))
+		}
+	case "string":
+		if len(s.Enum) != 0 && !contains(s.Enum, n.Value) {
+			v.add(n, path, "invalid value %q, expected one of: %q", n.Value, s.Enum)
+		}
+
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(n.Value) {
+			v.add(n, path, "invalid value %q, expected %s", n.Value, s.Description)
+		}
+	}
+}
+
+// checkOneOf checks the node n is valid against one of the s schema alternatives.
+// The problems of the alternative of the node's type are reported otherwise.
+func (v *inventoryValidator) checkOneOf(n *yaml.Node, s *jsonSchema, path string) {
+	types := make([]string, 0, len(s.OneOf))
+
+	for _, alt := range s.OneOf {
+		alt = v.schema.resolve(alt)
+		types = append(types, typeNames[alt.Type])
+
+		sub := &inventoryValidator{file: v.file, schema: v.schema}
+		sub.checkNode(n, alt, path)
+
+		if len(sub.problems) == 0 {
+			return
+		}
+
+		if nodeHasType(n, alt.Type) {
+			v.problems = append(v.problems, sub.problems...)
+
+			return
+		}
+	}
+
+	v.add(n, path, "expected %s", strings.Join(types, " or "))
+}
+
+// forEachKey calls f for every key of the mapping node n, reporting the duplicate keys.
+// The keys merged with "<<" are followed.
+func (v *inventoryValidator) forEachKey(n *yaml.Node, path string, f func(k, val *yaml.Node)) {
+	seen := map[string]int{}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, val := n.Content[i], n.Content[i+1]
+
+		if k.Value == "<<" && k.Tag == "!!merge" {
+			for _, m := range mergedMappings(val) {
+				v.forEachKey(m, path, f)
+			}
+
+			continue
+		}
+
+		if line, ok := seen[k.Value]; ok {
+			v.add(k, path, "%q is already set at line %d", k.Value, line)
+
+			continue
+		}
+
+		seen[k.Value] = k.Line
+
+		f(k, val)
+	}
+}
+
+// mergedSections are the sections of the inventory files merged by the names of their elements.
+var mergedSections = []string{"credentials", "transports", "devices", "groups", "vars"} //nolint:gochecknoglobals
+
+// checkInventory checks the values of the inventory files: the conflicts between the files,
+// the platforms, the references to the credentials, transports and groups,
+// the files and the cfg operations.
+func (v *inventoryValidator) checkInventory(files []*inventoryFile) {
+	names := v.checkConflicts(files)
+
+	devices := 0
+	parsed := true
+
+	for _, f := range files {
+		v.file = f.path
+
+		if f.root == nil || f.root.Kind != yaml.MappingNode {
+			parsed = parsed && f.parseErr == nil
+
+			continue
+		}
+
+		_, creds := mappingValue(f.root, "credentials")
+		eachMappingValue(creds, func(name string, _, n *yaml.Node) {
+			v.checkCredentials(n, joinPath("credentials", name))
+		})
+
+		_, transports := mappingValue(f.root, "transports")
+		eachMappingValue(transports, func(name string, _, n *yaml.Node) {
+			v.checkTransport(n, joinPath("transports", name))
+		})
+
+		_, normalize := mappingValue(f.root, "normalize")
+		v.checkNormalize(normalize)
+
+		_, groups := mappingValue(f.root, "groups")
+		eachMappingValue(groups, func(name string, _, n *yaml.Node) {
+			_, fn := mappingValue(n, "intended-config")
+			v.checkFile(fn, joinPath(joinPath("groups", name), "intended-config"))
+		})
+
+		_, devs := mappingValue(f.root, "devices")
+		eachMappingValue(devs, func(name string, k, n *yaml.Node) {
+			devices++
+
+			v.checkDevice(k, n, joinPath("devices", name), names)
+		})
+	}
+
+	if devices == 0 && parsed && len(files) != 0 {
+		v.file = files[len(files)-1].path
+		v.add(&yaml.Node{Line: 1, Column: 1}, "", "no devices defined")
+	}
+}
+
+// checkConflicts reports the elements defined in more than one file
+// and returns the names of the defined elements by the section.
+func (v *inventoryValidator) checkConflicts(files []*inventoryFile) map[string]map[string]struct{} {
+	type definition struct {
+		file string
+		pos  string
+	}
+
+	names := map[string]map[string]struct{}{}
+
+	for _, sec := range mergedSections {
+		defined := map[string]definition{}
+		names[sec] = map[string]struct{}{}
+
+		for _, f := range files {
+			v.file = f.path
+
+			_, n := mappingValue(f.root, sec)
+			eachMappingValue(n, func(name string, k, _ *yaml.Node) {
+				names[sec][name] = struct{}{}
+
+				// the duplicates within the file are reported by the schema check
+				if def, ok := defined[name]; ok {
+					if def.file != f.path {
+						v.add(k, joinPath(sec, name), "conflicts with the definition at %s", def.pos)
+					}
+
+					return
+				}
+
+				defined[name] = definition{file: f.path, pos: position(f, k)}
+			})
+		}
+	}
+
+	return names
+}
+
+// checkDevice checks the device n defined with the k key.
+func (v *inventoryValidator) checkDevice(k, n *yaml.Node, path string, names map[string]map[string]struct{}) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+
+	platform := v.checkPlatform(k, n, path)
+
+	if _, addr := mappingValue(n, "address"); addr == nil || addr.Value == "" {
+		v.add(k, path, "address is not set")
+	}
+
+	if _, c := mappingValue(n, "credentials"); c != nil {
+		for _, ref := range scalars(c) {
+			if _, ok := names["credentials"][ref.Value]; !ok {
+				v.add(ref, path+".credentials", "unknown credentials %q", ref.Value)
+			}
+		}
+	} else if _, ok := names["credentials"][defaultName]; !ok {
+		v.add(k, path, "credentials are not set and there are no %q credentials", defaultName)
+	}
+
+	if _, t := mappingValue(n, "transport"); t != nil && t.Value != defaultName {
+		if _, ok := names["transports"][t.Value]; !ok {
+			v.add(t, path+".transport", "unknown transport %q", t.Value)
+		}
+	}
+
+	if _, g := mappingValue(n, "groups"); g != nil {
+		for _, ref := range scalars(g) {
+			if _, ok := names["groups"][ref.Value]; !ok {
+				v.add(ref, path+".groups", "unknown group %q", ref.Value)
+			}
+		}
+	}
+
+	for _, f := range []string{"send-commands-from-file", "send-configs-from-file", "intended-config"} {
+		_, fn := mappingValue(n, f)
+		v.checkFile(fn, joinPath(path, f))
+	}
+
+	_, ops := mappingValue(n, "cfg-operations")
+	v.checkCfgOperations(ops, path+".cfg-operations", platform)
+}
+
+// checkPlatform checks the platform of the device n is set and returns it
+// if it is supported, or an empty string otherwise.
+func (v *inventoryValidator) checkPlatform(k, n *yaml.Node, path string) string {
+	_, p := mappingValue(n, "platform")
+
+	switch {
+	case p == nil || p.Value == "":
+		v.add(k, path, "platform is not set")
+	case contains(supportedPlatforms, p.Value):
+		return p.Value
+	}
+
+	// unsupported platforms are reported by the schema check
+	return ""
+}
+
+func (v *inventoryValidator) checkCfgOperations(ops *yaml.Node, path, platform string) {
+	if ops == nil || ops.Kind != yaml.SequenceNode {
+		return
+	}
+
+	for idx, n := range ops.Content {
+		n = resolveAlias(n)
+		opPath := fmt.Sprintf("%s[%d]", path, idx)
+
+		op := &cfgOperation{}
+		if err := n.Decode(op); err != nil {
+			// the structure problems are already reported
+			continue
+		}
+
+		_, t := mappingValue(n, "type")
+		if t == nil {
+			v.add(n, opPath, "type is not set")
+
+			continue
+		}
+
+		// the unknown types are reported by the schema check
+		if platform != "" && contains(cfgOperationTypes, t.Value) {
+			if err := op.validate(platform); err != nil {
+				v.add(n, opPath, "%s", strings.TrimPrefix(err.Error(), errInvalidCfgOperation.Error()+": "))
+			}
+		}
+
+		_, f := mappingValue(n, "config-from-file")
+		v.checkFile(f, opPath+".config-from-file")
+	}
+}
+
+func (v *inventoryValidator) checkCredentials(n *yaml.Node, path string) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+
+	for _, f := range []string{"username", "password", "secondary-password"} {
+		if k, s := mappingValue(n, f); s != nil && strings.HasPrefix(s.Value, fileSecretPrefix) {
+			v.checkFile(&yaml.Node{
+				Value:  strings.TrimPrefix(s.Value, fileSecretPrefix),
+				Line:   s.Line,
+				Column: s.Column,
+			}, joinPath(path, k.Value))
+		}
+	}
+
+	_, key := mappingValue(n, "private-key")
+	v.checkResolvedFile(key, path+".private-key")
+}
+
+func (v *inventoryValidator) checkTransport(n *yaml.Node, path string) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+
+	_, f := mappingValue(n, "ssh-config-file")
+	v.checkResolvedFile(f, path+".ssh-config-file")
+}
+
+func (v *inventoryValidator) checkNormalize(n *yaml.Node) {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return
+	}
+
+	for idx, r := range n.Content {
+		path := fmt.Sprintf("normalize[%d]", idx)
+
+		if _, drop := mappingValue(resolveAlias(r), "drop-lines"); drop != nil {
+			for _, p := range scalars(drop) {
+				v.checkRegexp(p, path+".drop-lines")
+			}
+		}
+
+		if _, replace := mappingValue(resolveAlias(r), "replace"); replace != nil && replace.Kind == yaml.SequenceNode {
+			for _, rr := range replace.Content {
+				_, p := mappingValue(resolveAlias(rr), "pattern")
+				v.checkRegexp(p, path+".replace.pattern")
+			}
+		}
+	}
+}
+
+func (v *inventoryValidator) checkRegexp(n *yaml.Node, path string) {
+	if n == nil {
+		return
+	}
+
+	if _, err := regexp.Compile(n.Value); err != nil {
+		v.add(n, path, "invalid pattern: %v", err)
+	}
+}
+
+// checkFile checks the file the node n refers to exists.
+func (v *inventoryValidator) checkFile(n *yaml.Node, path string) {
+	if n == nil || n.Value == "" {
+		return
+	}
+
+	if _, err := os.Stat(n.Value); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			v.add(n, path, "file %q does not exist", n.Value)
+
+			return
+		}
+
+		v.add(n, path, "%v", err)
+	}
+}
+
+// checkResolvedFile checks the file the node n refers to exists,
+// looking it up in the home directory as scrapligo does.
+func (v *inventoryValidator) checkResolvedFile(n *yaml.Node, path string) {
+	if n == nil || n.Value == "" {
+		return
+	}
+
+	if _, err := util.ResolveFilePath(n.Value); err != nil {
+		v.add(n, path, "file %q does not exist", n.Value)
+	}
+}
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+
+	return n
+}
+
+// mergedMappings returns the mappings merged with the "<<" key.
+func mergedMappings(n *yaml.Node) []*yaml.Node {
+	n = resolveAlias(n)
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		return []*yaml.Node{n}
+	case yaml.SequenceNode:
+		var ms []*yaml.Node
+		for _, e := range n.Content {
+			ms = append(ms, mergedMappings(e)...)
+		}
+
+		return ms
+	}
+
+	return nil
+}
+
+// mappingValue returns the key and the value nodes of the key in the mapping node n,
+// or nils if the key is not set.
+func mappingValue(n *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i], resolveAlias(n.Content[i+1])
+		}
+	}
+
+	return nil, nil
+}
+
+func eachMappingValue(n *yaml.Node, f func(name string, k, val *yaml.Node)) {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		f(n.Content[i].Value, n.Content[i], resolveAlias(n.Content[i+1]))
+	}
+}
+
+// scalars returns the scalar node n, or the scalar elements of the sequence node n.
+func scalars(n *yaml.Node) []*yaml.Node {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return []*yaml.Node{n}
+	case yaml.SequenceNode:
+		var s []*yaml.Node
+
+		for _, e := range n.Content {
+			if e = resolveAlias(e); e.Kind == yaml.ScalarNode {
+				s = append(s, e)
+			}
+		}
+
+		return s
+	}
+
+	return nil
+}
+
+// nodeHasType returns true if the node n holds a value of the schema type t.
+// The yaml 1.1 booleans accepted when the inventory is loaded are booleans too.
+func nodeHasType(n *yaml.Node, t string) bool {
+	switch t {
+	case "object":
+		return n.Kind == yaml.MappingNode
+	case "array":
+		return n.Kind == yaml.SequenceNode
+	case "string":
+		return n.Kind == yaml.ScalarNode
+	case "integer":
+		return n.Kind == yaml.ScalarNode && n.Tag == "!!int"
+	case "boolean":
+		if n.Kind != yaml.ScalarNode {
+			return false
+		}
+
+		switch strings.ToLower(n.Value) {
+		case "y", "yes", "n", "no", "on", "off":
+			return n.Style == 0
+		}
+
+		return n.Tag == "!!bool"
+	}
+
+	// any value
+	return true
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// checkInventory validates the inventory files and prints the problems found to stderr.
+func (app *appCfg) checkInventory(files []*inventoryFile) error {
+	problems := validateInventory(files)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, p)
+	}
+
+	return cli.Exit(fmt.Sprintf("inventory is invalid: %d problem(s) found", len(problems)), exitCodeFailed)
+}
+
+// runValidate validates the inventory without connecting to the devices.
+func (app *appCfg) runValidate() error {
+	files, err := app.readInventoryFiles()
+	if err != nil {
+		return err
+	}
+
+	if err := app.checkInventory(files); err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.path)
+	}
+
+	log.Infof("inventory is valid: %s", strings.Join(paths, ", "))
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package commando
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateInventory(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		main  []string
+		want  []string
+	}{
+		{
+			name: "valid",
+			files: map[string]string{"inventory.yml": `credentials:
+  default:
+    username: admin
+devices:
+  r1:
+    platform: arista_eos
+    address: 192.0.2.1
+`},
+			main: []string{"inventory.yml"},
+			want: []string{},
+		},
+		{
+			name:  "empty",
+			files: map[string]string{"inventory.yml": ""},
+			main:  []string{"inventory.yml"},
+			want: []string{
+				"inventory.yml:1:1: inventory is empty",
+				"inventory.yml:1:1: no devices defined",
+			},
+		},
+		{
+			name:  "syntax error",
+			files: map[string]string{"inventory.yml": "devices:\n  r1: [\n"},
+			main:  []string{"inventory.yml"},
+			want:  []string{"inventory.yml:2: did not find expected node content"},
+		},
+		{
+			name: "device problems",
+			files: map[string]string{"inventory.yml": `devices:
+  r1:
+    platfrom: arista_eos
+    transport: ssh
+    groups: [core]
+    cfg-operations:
+      - type: get-config
+      - type: reboot
+      - source: running
+`},
+			main: []string{"inventory.yml"},
+			want: []string{
+				`inventory.yml:2:3: devices.r1: platform is not set`,
+				`inventory.yml:2:3: devices.r1: address is not set`,
+				`inventory.yml:2:3: devices.r1: credentials are not set and there are no "default" credentials`,
+				`inventory.yml:3:5: devices.r1: unknown field "platfrom"`,
+				`inventory.yml:4:16: devices.r1.transport: unknown transport "ssh"`,
+				`inventory.yml:5:14: devices.r1.groups: unknown group "core"`,
+				`inventory.yml:8:15: devices.r1.cfg-operations[1].type: invalid value "reboot", expected one of: ` +
+					`["get-config" "load-config" "validate" "save-config" "rollback" "get-version" ` +
+					`"cleanup" "exclusive" "shared"]`,
+				`inventory.yml:9:9: devices.r1.cfg-operations[2]: type is not set`,
+			},
+		},
+		{
+			name: "missing files",
+			files: map[string]string{"inventory.yml": `credentials:
+  default:
+    username: admin
+groups:
+  leaf:
+    intended-config: missing/leaf.cfg
+devices:
+  r1:
+    platform: arista_eos
+    address: 192.0.2.1
+    groups: [leaf]
+    intended-config: missing/r1.cfg
+`},
+			main: []string{"inventory.yml"},
+			want: []string{
+				`inventory.yml:6:22: groups.leaf.intended-config: file "missing/leaf.cfg" does not exist`,
+				`inventory.yml:12:22: devices.r1.intended-config: file "missing/r1.cfg" does not exist`,
+			},
+		},
+		{
+			name: "conflicts across files",
+			files: map[string]string{
+				"inventory.yml": `include: [other.yml]
+credentials:
+  default:
+    username: admin
+devices:
+  r1:
+    platform: arista_eos
+    address: 192.0.2.1
+`,
+				"other.yml": `devices:
+  r1:
+    platform: arista_eos
+    address: 192.0.2.100
+`,
+			},
+			main: []string{"inventory.yml"},
+			want: []string{`inventory.yml:6:3: devices.r1: conflicts with the definition at other.yml:2:3`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, files := writeInventories(t, tt.files, tt.main...)
+
+			got := []string{}
+			for _, m := range validateInventory(files) {
+				got = append(got, strings.ReplaceAll(m, dir+"/", ""))
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,537 @@
+package commando
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	vaultHeader = "$CMDO_VAULT;1.0;AES256"
+	// header of the data encrypted with the vault key instead of the passphrase
+	vaultKeyHeader = vaultHeader + ";KEY"
+	vaultLineWidth = 64
+	// prefix of the vault key in the key file, followed by the base64 encoded key
+	vaultKeyPrefix = "CMDO-VAULT-KEY-"
+
+	vaultSaltSize           = 16
+	vaultKeySize            = 32
+	vaultKeyFilePermissions = 0o600
+	// scrypt parameters recommended for interactive logins
+	vaultScryptN = 32768
+	vaultScryptR = 8
+	vaultScryptP = 1
+
+	credentialsKey = "credentials"
+)
+
+// vault actions.
+const (
+	vaultEncrypt = "encrypt"
+	vaultDecrypt = "decrypt"
+	vaultEdit    = "edit"
+	vaultKeygen  = "keygen"
+)
+
+// vaultSecret is the passphrase or the key the vault data is encrypted with.
+type vaultSecret struct {
+	pass string
+	key  []byte // random key read from the key file, used as is
+}
+
+// header returns the vault header of the data encrypted with the secret.
+func (s *vaultSecret) header() string {
+	if s.key != nil {
+		return vaultKeyHeader
+	}
+
+	return vaultHeader
+}
+
+// cipherKey returns the encryption key: the vault key, or the key derived from the passphrase and salt.
+func (s *vaultSecret) cipherKey(salt []byte) ([]byte, error) {
+	if s.key != nil {
+		return s.key, nil
+	}
+
+	return scrypt.Key([]byte(s.pass), salt, vaultScryptN, vaultScryptR, vaultScryptP, vaultKeySize)
+}
+
+// isVaulted returns true if b is the vault encrypted data.
+func isVaulted(b []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(b), []byte(vaultHeader))
+}
+
+// encryptVault encrypts the plain data with the passphrase or key using AES-256-GCM.
+// The result is the vault header followed by the base64 encoded salt, nonce and ciphertext.
+func encryptVault(plain []byte, s *vaultSecret) ([]byte, error) {
+	salt := make([]byte, vaultSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := vaultCipher(s, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	data := append(append(salt, nonce...), gcm.Seal(nil, nonce, plain, []byte(s.header()))...)
+	enc := base64.StdEncoding.EncodeToString(data)
+
+	var b strings.Builder
+
+	b.WriteString(s.header() + "\n")
+
+	for len(enc) > vaultLineWidth {
+		b.WriteString(enc[:vaultLineWidth] + "\n")
+		enc = enc[vaultLineWidth:]
+	}
+
+	b.WriteString(enc + "\n")
+
+	return []byte(b.String()), nil
+}
+
+// decryptVault decrypts the vault encrypted data with the passphrase or key,
+// depending on which of them the data was encrypted with.
+func decryptVault(vault []byte, s *vaultSecret) ([]byte, error) {
+	header, body, _ := strings.Cut(strings.TrimSpace(string(vault)), "\n")
+
+	switch strings.TrimSpace(header) {
+	case vaultKeyHeader:
+		if s.key == nil {
+			return nil, errVaultKeyRequired
+		}
+	case vaultHeader:
+		if s.key != nil {
+			return nil, errVaultPassRequired
+		}
+	default:
+		return nil, errVaultDecrypt
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errVaultDecrypt, err)
+	}
+
+	if len(data) < vaultSaltSize {
+		return nil, errVaultDecrypt
+	}
+
+	gcm, err := vaultCipher(s, data[:vaultSaltSize])
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[vaultSaltSize:]
+	if len(data) < gcm.NonceSize() {
+		return nil, errVaultDecrypt
+	}
+
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(s.header()))
+	if err != nil {
+		return nil, errVaultDecrypt
+	}
+
+	return plain, nil
+}
+
+func vaultCipher(s *vaultSecret, salt []byte) (cipher.AEAD, error) {
+	key, err := s.cipherKey(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// credentialsBlock returns the range [start, end) of the lines holding
+// the top-level credentials section of the inventory.
+func credentialsBlock(lines []string) (int, int, bool) {
+	start := -1
+
+	for i, l := range lines {
+		if start == -1 {
+			if strings.HasPrefix(l, credentialsKey+":") {
+				start = i
+			}
+
+			continue
+		}
+
+		// the section ends at the next top-level key
+		if l != "" && !strings.HasPrefix(l, " ") && !strings.HasPrefix(l, "\t") && !strings.HasPrefix(l, "#") {
+			return start, trimBlankLines(lines, start, i), true
+		}
+	}
+
+	if start == -1 {
+		return 0, 0, false
+	}
+
+	return start, trimBlankLines(lines, start, len(lines)), true
+}
+
+// trimBlankLines returns the end of the lines range [start, end) without the trailing blank lines.
+func trimBlankLines(lines []string, start, end int) int {
+	for end > start+1 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+
+	return end
+}
+
+// vaultedCredentials returns the encrypted credentials section of the inventory b,
+// if it is vault encrypted.
+func vaultedCredentials(b []byte) (string, bool) {
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return "", false
+	}
+
+	s, ok := doc[credentialsKey].(string)
+
+	return s, ok && isVaulted([]byte(s))
+}
+
+// credentialsSplice is the credentials section spliced into the inventory,
+// which maps the lines of the spliced inventory back to the lines of the file.
+type credentialsSplice struct {
+	start     int // line of the credentials key
+	fileLines int // lines of the section in the file
+	lines     int // lines of the spliced section
+}
+
+// fileLine returns the line of the file the line l of the spliced inventory comes from.
+// The lines of the spliced section don't exist in the file, they are mapped to the credentials key line.
+func (s *credentialsSplice) fileLine(l int) int {
+	switch {
+	case s == nil || l <= s.start:
+		return l
+	case l < s.start+s.lines:
+		return s.start
+	default:
+		return l - s.lines + s.fileLines
+	}
+}
+
+// replaceCredentials replaces the credentials section of the inventory b with the section text,
+// keeping the rest of the file intact.
+func replaceCredentials(b []byte, section string) ([]byte, *credentialsSplice, error) {
+	lines := strings.Split(string(b), "\n")
+
+	start, end, ok := credentialsBlock(lines)
+	if !ok {
+		return nil, nil, errNoCredentialsSection
+	}
+
+	sectionLines := strings.Split(strings.TrimRight(section, "\n"), "\n")
+
+	replaced := append([]string{}, lines[:start]...)
+	replaced = append(replaced, sectionLines...)
+	replaced = append(replaced, lines[end:]...)
+
+	sp := &credentialsSplice{start: start + 1, fileLines: end - start, lines: len(sectionLines)}
+
+	return []byte(strings.Join(replaced, "\n")), sp, nil
+}
+
+// decryptInventory decrypts the inventory b if the whole file or its credentials section
+// is vault encrypted. The inventory is returned as is otherwise.
+// The splice of the decrypted credentials section is returned when only the section is encrypted.
+func (app *appCfg) decryptInventory(b []byte) ([]byte, *credentialsSplice, error) {
+	if isVaulted(b) {
+		s, err := app.vaultSecret(false)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		plain, err := decryptVault(b, s)
+
+		return plain, nil, err
+	}
+
+	creds, ok := vaultedCredentials(b)
+	if !ok {
+		return b, nil, nil
+	}
+
+	s, err := app.vaultSecret(false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the encrypted section holds the original text of the credentials section
+	plain, err := decryptVault([]byte(creds), s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return replaceCredentials(b, string(plain))
+}
+
+// vaultSecret returns the vault key or passphrase read from the vault key file,
+// or the passphrase prompted for if the key file is not set. The key file holds either the key
+// generated with the vault keygen subcommand, or the passphrase on a single line.
+// The passphrase is prompted twice when confirm is set. The secret is read once and reused afterwards.
+func (app *appCfg) vaultSecret(confirm bool) (*vaultSecret, error) {
+	if app.vault != nil {
+		return app.vault, nil
+	}
+
+	s := &vaultSecret{}
+
+	if app.vaultKeyFile != "" {
+		b, err := os.ReadFile(app.vaultKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.pass = strings.TrimRight(string(b), "\r\n"); strings.HasPrefix(s.pass, vaultKeyPrefix) {
+			key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s.pass, vaultKeyPrefix))
+			if err != nil || len(key) != vaultKeySize {
+				return nil, fmt.Errorf("%w: %s", errInvalidVaultKey, app.vaultKeyFile)
+			}
+
+			s.pass, s.key = "", key
+		}
+	} else {
+		if app.commands == stdinArg {
+			return nil, errStdinInUse
+		}
+
+		pass, err := promptSecret("Vault passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+
+		if confirm {
+			again, err := promptSecret("Confirm vault passphrase: ")
+			if err != nil {
+				return nil, err
+			}
+
+			if again != pass {
+				return nil, errVaultPassMismatch
+			}
+		}
+
+		s.pass = pass
+	}
+
+	if s.pass == "" && s.key == nil {
+		return nil, errNoVaultPass
+	}
+
+	app.vault = s
+
+	return s, nil
+}
+
+// newVaultKey returns the contents of the new vault key file holding the random key.
+func newVaultKey() ([]byte, error) {
+	key := make([]byte, vaultKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return []byte(vaultKeyPrefix + base64.StdEncoding.EncodeToString(key) + "\n"), nil
+}
+
+// runVaultKeygen writes the new vault key to the f file, which must not exist.
+func runVaultKeygen(f string) error {
+	b, err := newVaultKey()
+	if err != nil {
+		return err
+	}
+
+	kf, err := os.OpenFile(f, os.O_WRONLY|os.O_CREATE|os.O_EXCL, vaultKeyFilePermissions)
+	if err != nil {
+		return err
+	}
+
+	if _, err := kf.Write(b); err != nil {
+		kf.Close()
+
+		return err
+	}
+
+	if err := kf.Close(); err != nil {
+		return err
+	}
+
+	log.Infof("vault key has been written to %s", f)
+
+	return nil
+}
+
+// promptSecret prompts for a secret on the terminal without echoing it.
+func promptSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("%w: %s", errNoTerminal, strings.TrimSuffix(prompt, ": "))
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+
+	b, err := term.ReadPassword(fd)
+
+	fmt.Fprintln(os.Stderr)
+
+	return string(b), err
+}
+
+// runVault runs the vault action on the f file. The encrypt action encrypts the whole file,
+// or only its credentials section if credsOnly is set. The decrypt and edit actions
+// keep the encryption mode of the file.
+func (app *appCfg) runVault(action, f string, credsOnly bool) error {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(f)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case vaultEncrypt:
+		if isVaulted(b) {
+			return fmt.Errorf("%w: %s", errAlreadyVaulted, f)
+		}
+
+		if _, ok := vaultedCredentials(b); ok {
+			return fmt.Errorf("%w: %s", errAlreadyVaulted, f)
+		}
+
+		if b, err = app.encryptFile(b, credsOnly); err != nil {
+			return err
+		}
+	case vaultDecrypt:
+		if b, err = app.decryptFile(f, b); err != nil {
+			return err
+		}
+	case vaultEdit:
+		credsOnly = !isVaulted(b)
+
+		if b, err = app.decryptFile(f, b); err != nil {
+			return err
+		}
+
+		if b, err = editInEditor(b); err != nil {
+			return err
+		}
+
+		if b, err = app.encryptFile(b, credsOnly); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(f, b, fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	log.Infof("%s has been %sed", f, strings.TrimSuffix(action, "e"))
+
+	return nil
+}
+
+// encryptFile encrypts the whole file b or only its credentials section.
+func (app *appCfg) encryptFile(b []byte, credsOnly bool) ([]byte, error) {
+	s, err := app.vaultSecret(true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !credsOnly {
+		return encryptVault(b, s)
+	}
+
+	lines := strings.Split(string(b), "\n")
+
+	start, end, ok := credentialsBlock(lines)
+	if !ok {
+		return nil, errNoCredentialsSection
+	}
+
+	enc, err := encryptVault([]byte(strings.Join(lines[start:end], "\n")), s)
+	if err != nil {
+		return nil, err
+	}
+
+	section := credentialsKey + ": |\n  " + strings.ReplaceAll(strings.TrimRight(string(enc), "\n"), "\n", "\n  ")
+
+	b, _, err = replaceCredentials(b, section)
+
+	return b, err
+}
+
+// decryptFile decrypts the vault encrypted file b.
+func (app *appCfg) decryptFile(f string, b []byte) ([]byte, error) {
+	if _, ok := vaultedCredentials(b); !isVaulted(b) && !ok {
+		return nil, fmt.Errorf("%w: %s", errNotVaulted, f)
+	}
+
+	b, _, err := app.decryptInventory(b)
+
+	return b, err
+}
+
+// editInEditor opens the contents b in the $EDITOR (vi by default) and returns the edited contents.
+// The temporary file holding the decrypted contents is removed afterwards.
+func editInEditor(b []byte) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "cmdo-vault-*.yml")
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	tmp.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+
+	args := append(strings.Fields(editor), tmp.Name())
+
+	cmd := exec.Command(args[0], args[1:]...) //nolint:gosec
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor %s failed: %w", editor, err)
+	}
+
+	return os.ReadFile(tmp.Name())
+}
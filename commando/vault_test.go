@@ -0,0 +1,181 @@
+package commando
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const vaultTestInventory = `credentials:
+  default:
+    username: admin
+    password: secret
+
+devices:
+  r1:
+    platform: arista_eos
+    address: 192.0.2.1
+`
+
+func testVaultKey(t *testing.T) *vaultSecret {
+	t.Helper()
+
+	app := &appCfg{vaultKeyFile: t.TempDir() + "/key"}
+	if err := runVaultKeygen(app.vaultKeyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(app.vaultKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(b), vaultKeyPrefix) {
+		t.Fatalf("key file %q doesn't start with %q", b, vaultKeyPrefix)
+	}
+
+	s, err := app.vaultSecret(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.key) != vaultKeySize {
+		t.Fatalf("got key of %d bytes, want %d", len(s.key), vaultKeySize)
+	}
+
+	return s
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	key := testVaultKey(t)
+
+	tests := []struct {
+		name       string
+		secret     *vaultSecret
+		credsOnly  bool
+		wantHeader string
+	}{
+		{"passphrase", &vaultSecret{pass: "passphrase"}, false, vaultHeader},
+		{"passphrase credentials only", &vaultSecret{pass: "passphrase"}, true, vaultHeader},
+		{"key", key, false, vaultKeyHeader},
+		{"key credentials only", key, true, vaultKeyHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &appCfg{vault: tt.secret}
+
+			enc, err := app.encryptFile([]byte(vaultTestInventory), tt.credsOnly)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Contains(string(enc), "secret") {
+				t.Fatal("encrypted inventory holds the plaintext password")
+			}
+
+			if !strings.Contains(string(enc), tt.wantHeader+"\n") {
+				t.Fatalf("encrypted inventory has no %q header:\n%s", tt.wantHeader, enc)
+			}
+
+			// the devices stay readable in the credentials only mode
+			if got := strings.Contains(string(enc), "platform: arista_eos"); got != tt.credsOnly {
+				t.Fatalf("devices readable = %v, want %v", got, tt.credsOnly)
+			}
+
+			dec, _, err := app.decryptInventory(enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(dec) != vaultTestInventory {
+				t.Fatalf("decrypted inventory differs:\n%s", dec)
+			}
+		})
+	}
+}
+
+func TestDecryptVaultErrors(t *testing.T) {
+	key := testVaultKey(t)
+
+	byPass, err := encryptVault([]byte("data"), &vaultSecret{pass: "passphrase"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byKey, err := encryptVault([]byte("data"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		vault   []byte
+		secret  *vaultSecret
+		wantErr error
+	}{
+		{"wrong passphrase", byPass, &vaultSecret{pass: "wrong"}, errVaultDecrypt},
+		{"key for passphrase data", byPass, key, errVaultPassRequired},
+		{"passphrase for key data", byKey, &vaultSecret{pass: "passphrase"}, errVaultKeyRequired},
+		{"wrong key", byKey, &vaultSecret{key: make([]byte, vaultKeySize)}, errVaultDecrypt},
+		{"corrupted data", []byte(vaultHeader + "\n!!!"), &vaultSecret{pass: "passphrase"}, errVaultDecrypt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decryptVault(tt.vault, tt.secret); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateVaultedCredentialsLines(t *testing.T) {
+	app := &appCfg{vault: &vaultSecret{pass: "passphrase"}}
+
+	enc, err := app.encryptFile([]byte(`credentials:
+  default:
+    username: admin
+    pasword: secret
+devices:
+  r1:
+    platfrom: arista_eos
+    address: 192.0.2.1
+`), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the lines of the device in the encrypted file
+	lines := map[string]int{}
+
+	for idx, l := range strings.Split(string(enc), "\n") {
+		lines[strings.TrimSpace(l)] = idx + 1
+	}
+
+	dir, _ := writeInventories(t, map[string]string{"inventory.yml": string(enc)})
+	app.inventories = []string{dir + "/inventory.yml"}
+
+	files, err := app.readInventoryFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := validateInventory(files)
+	for idx := range got {
+		got[idx] = strings.ReplaceAll(got[idx], dir+"/", "")
+	}
+
+	want := []string{
+		`inventory.yml:1:1: credentials.default: unknown field "pasword"`,
+		fmt.Sprintf(`inventory.yml:%d:3: devices.r1: platform is not set`, lines["r1:"]),
+		fmt.Sprintf(`inventory.yml:%d:5: devices.r1: unknown field "platfrom"`, lines["platfrom: arista_eos"]),
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}